@@ -0,0 +1,178 @@
+// acmeJws.go
+// signed acme requests that acme.Client does not provide
+// (orders with the replaces field, alternate certificate chains)
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+)
+
+// the endpoints of the acme directory that are not available in acme.Directory
+type acmeDirRaw struct {
+	NewNonce string `json:"newNonce"`
+	NewOrder string `json:"newOrder"`
+	RenewalInfo string `json:"renewalInfo"`
+}
+
+// acme problem document
+type acmeProblem struct {
+	Type string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+func httpClient(client *acme.Client) (hc *http.Client) {
+	if client != nil && client.HTTPClient != nil {return client.HTTPClient}
+	return http.DefaultClient
+}
+
+func fetchAcmeDir(hc *http.Client, dirUrl string) (dir *acmeDirRaw, err error) {
+
+	if len(dirUrl) == 0 {return nil, fmt.Errorf("no directory url!")}
+	resp, err := hc.Get(dirUrl)
+	if err != nil {return nil, fmt.Errorf("http get %s: %v", dirUrl, err)}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {return nil, fmt.Errorf("http get %s: status %s!", dirUrl, resp.Status)}
+
+	dir = &acmeDirRaw{}
+	err = json.NewDecoder(resp.Body).Decode(dir)
+	if err != nil {return nil, fmt.Errorf("json decode directory: %v", err)}
+	return dir, nil
+}
+
+
+// function that returns the account url used as kid of the signed requests
+func acntKid(ctx context.Context, client *acme.Client) (kid string, err error) {
+
+	kid = string(client.KID)
+	if len(kid) > 0 {return kid, nil}
+	acnt, err := client.GetReg(ctx, "")
+	if err != nil {return "", fmt.Errorf("client.GetReg: %v", err)}
+	return acnt.URI, nil
+}
+
+// function that posts a request signed with the account key
+// a nil payload sends a POST-as-GET request; a bad nonce is retried once
+// responses other than wantStatus are returned as error
+func postJws(ctx context.Context, client *acme.Client, dir *acmeDirRaw, kid string, url string, payload []byte, wantStatus int) (hdr http.Header, respByt []byte, err error) {
+
+	hc := httpClient(client)
+	for attempt:=0; ; attempt++ {
+		nonce, err := fetchNonce(hc, dir.NewNonce)
+		if err != nil {return nil, nil, fmt.Errorf("fetchNonce: %v", err)}
+
+		body, err := jwsEncode(client.Key, kid, nonce, url, payload)
+		if err != nil {return nil, nil, fmt.Errorf("jwsEncode: %v", err)}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {return nil, nil, fmt.Errorf("http.NewRequest: %v", err)}
+		httpReq.Header.Set("Content-Type", "application/jose+json")
+
+		resp, err := hc.Do(httpReq)
+		if err != nil {return nil, nil, fmt.Errorf("post %s: %v", url, err)}
+		respByt, _ = io.ReadAll(io.LimitReader(resp.Body, 1 << 20))
+		resp.Body.Close()
+
+		if resp.StatusCode == wantStatus {return resp.Header, respByt, nil}
+
+		prob := acmeProblem{}
+		json.Unmarshal(respByt, &prob)
+		if prob.Type == "urn:ietf:params:acme:error:badNonce" && attempt == 0 {continue}
+		return nil, nil, fmt.Errorf("post %s: status %s type: %s detail: %s", url, resp.Status, prob.Type, prob.Detail)
+	}
+}
+
+func fetchNonce(hc *http.Client, nonceUrl string) (nonce string, err error) {
+
+	resp, err := hc.Head(nonceUrl)
+	if err != nil {return "", fmt.Errorf("http head %s: %v", nonceUrl, err)}
+	resp.Body.Close()
+
+	nonce = resp.Header.Get("Replay-Nonce")
+	if len(nonce) == 0 {return "", fmt.Errorf("no Replay-Nonce header!")}
+	return nonce, nil
+}
+
+// function that creates a flattened jws (RFC 7515) signed with the account key
+func jwsEncode(key crypto.Signer, kid string, nonce string, url string, payload []byte) (body []byte, err error) {
+
+	alg := ""
+	var hash crypto.Hash
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			alg, hash = "ES256", crypto.SHA256
+		case elliptic.P384():
+			alg, hash = "ES384", crypto.SHA384
+		default:
+			return nil, fmt.Errorf("unsupported ecdsa curve!")
+		}
+	case *rsa.PublicKey:
+		alg, hash = "RS256", crypto.SHA256
+	default:
+		return nil, fmt.Errorf("unsupported account key type %T!", pub)
+	}
+
+	prot := map[string]string{"alg": alg, "kid": kid, "nonce": nonce, "url": url}
+	protByt, err := json.Marshal(prot)
+	if err != nil {return nil, fmt.Errorf("json marshal protected: %v", err)}
+
+	enc := base64.RawURLEncoding
+	prot64 := enc.EncodeToString(protByt)
+	payload64 := enc.EncodeToString(payload)
+
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256([]byte(prot64 + "." + payload64))
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(prot64 + "." + payload64))
+		digest = sum[:]
+	}
+
+	sig, err := key.Sign(rand.Reader, digest, hash)
+	if err != nil {return nil, fmt.Errorf("sign: %v", err)}
+
+	// ecdsa signatures are asn1 encoded; jws uses the fixed size concatenation r || s
+	if ecPub, ok := key.Public().(*ecdsa.PublicKey); ok {
+		var esig struct {R, S *big.Int}
+		_, err = asn1.Unmarshal(sig, &esig)
+		if err != nil {return nil, fmt.Errorf("asn1.Unmarshal signature: %v", err)}
+		size := (ecPub.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		esig.R.FillBytes(sig[:size])
+		esig.S.FillBytes(sig[size:])
+	}
+
+	jws := map[string]string{
+		"protected": prot64,
+		"payload": payload64,
+		"signature": enc.EncodeToString(sig),
+	}
+	body, err = json.Marshal(jws)
+	if err != nil {return nil, fmt.Errorf("json marshal jws: %v", err)}
+	return body, nil
+}
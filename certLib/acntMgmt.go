@@ -0,0 +1,89 @@
+// acntMgmt.go
+// functions that manage a CA account: update of the contacts and deactivation
+// the state of the account is recorded in the yaml account file
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// function that adds the mailto scheme to email addresses
+func NormContacts(contacts []string) (normList []string, err error) {
+
+	for _, contact := range contacts {
+		contact = strings.TrimSpace(contact)
+		if len(contact) == 0 {continue}
+		if !strings.HasPrefix(contact, "mailto:") {
+			if !strings.Contains(contact, "@") {return nil, fmt.Errorf("contact %s is not an email address!", contact)}
+			contact = "mailto:" + contact
+		}
+		normList = append(normList, contact)
+	}
+	return normList, nil
+}
+
+// function that replaces the contacts of the account acntNam at the CA
+// if contacts is nil, the contacts of the account file are sent
+func UpdateLEContacts(acntNam string, contacts []string, dbg bool) (le *LEObj, err error) {
+
+	le, err = ReadLEObj(acntNam)
+	if err != nil {return nil, fmt.Errorf("ReadLEObj: %v", err)}
+	if contacts == nil {contacts = le.Contacts}
+
+	contacts, err = NormContacts(contacts)
+	if err != nil {return nil, fmt.Errorf("NormContacts: %v", err)}
+	// acme.Client.UpdateReg omits an empty contact list, so the contacts cannot be removed
+	if len(contacts) == 0 {return nil, fmt.Errorf("no contacts!")}
+
+	client, err := GetLEClient(acntNam, dbg)
+	if err != nil {return nil, fmt.Errorf("GetLEClient: %v", err)}
+
+	acntTpl := acme.Account{
+		Contact: contacts,
+	}
+
+	ctx := context.Background()
+	acnt, err := client.UpdateReg(ctx, &acntTpl)
+	if err != nil {return nil, fmt.Errorf("client.UpdateReg: %v", err)}
+	if dbg {PrintAccount(acnt)}
+
+	le.Contacts = acnt.Contact
+	le.Status = acnt.Status
+	le.ContactsUpdated = time.Now()
+
+	err = WriteLEObj(acntNam, le)
+	if err != nil {return nil, fmt.Errorf("contacts updated but WriteLEObj: %v", err)}
+	return le, nil
+}
+
+// function that deactivates the account acntNam at the CA
+// a deactivated account cannot be used again; GetLEClient refuses it
+func DeactivateLEAcnt(acntNam string, dbg bool) (le *LEObj, err error) {
+
+	le, err = ReadLEObj(acntNam)
+	if err != nil {return nil, fmt.Errorf("ReadLEObj: %v", err)}
+
+	client, err := GetLEClient(acntNam, dbg)
+	if err != nil {return nil, fmt.Errorf("GetLEClient: %v", err)}
+
+	ctx := context.Background()
+	err = client.DeactivateReg(ctx)
+	if err != nil {return nil, fmt.Errorf("client.DeactivateReg: %v", err)}
+
+	le.Status = acme.StatusDeactivated
+	le.Deactivated = time.Now()
+
+	err = WriteLEObj(acntNam, le)
+	if err != nil {return nil, fmt.Errorf("account deactivated but WriteLEObj: %v", err)}
+	return le, nil
+}
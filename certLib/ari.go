@@ -0,0 +1,193 @@
+// ari.go
+// support for the acme renewal information extension (ARI, RFC 9773)
+// the CA suggests a renewal window for each certificate; a new order names the certificate it replaces
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// wait before the next renewalInfo request if the CA sends no Retry-After header
+const DefaultAriRetry = 6 * time.Hour
+
+type AriWindow struct {
+	Start time.Time `json:"start"`
+	End time.Time `json:"end"`
+}
+
+// response of the renewalInfo endpoint
+type RenewalInfo struct {
+	SuggestedWindow AriWindow `json:"suggestedWindow"`
+	ExplanationURL string `json:"explanationURL"`
+}
+
+// function that computes the ARI certificate identifier
+// base64url(authority key identifier) "." base64url(serial number)
+func AriCertId(cert *x509.Certificate) (certId string, err error) {
+
+	if len(cert.AuthorityKeyId) == 0 {return "", fmt.Errorf("certificate has no authority key identifier!")}
+	if cert.SerialNumber == nil {return "", fmt.Errorf("certificate has no serial number!")}
+
+	// the serial number is encoded as the content octets of its DER integer
+	serDer, err := asn1.Marshal(cert.SerialNumber)
+	if err != nil {return "", fmt.Errorf("asn1.Marshal serial: %v", err)}
+	var raw asn1.RawValue
+	_, err = asn1.Unmarshal(serDer, &raw)
+	if err != nil {return "", fmt.Errorf("asn1.Unmarshal serial: %v", err)}
+
+	enc := base64.RawURLEncoding
+	certId = enc.EncodeToString(cert.AuthorityKeyId) + "." + enc.EncodeToString(raw.Bytes)
+	return certId, nil
+}
+
+// function that fetches the suggested renewal window of a certificate
+// retry is the time the CA asks to wait before the next request; a nil hc selects http.DefaultClient
+func FetchRenewalInfo(hc *http.Client, dirUrl string, certId string) (info *RenewalInfo, retry time.Duration, err error) {
+
+	if hc == nil {hc = http.DefaultClient}
+	dir, err := fetchAcmeDir(hc, dirUrl)
+	if err != nil {return nil, 0, err}
+	if len(dir.RenewalInfo) == 0 {return nil, 0, fmt.Errorf("CA does not support renewalInfo!")}
+
+	infoUrl := strings.TrimSuffix(dir.RenewalInfo, "/") + "/" + certId
+	resp, err := hc.Get(infoUrl)
+	if err != nil {return nil, 0, fmt.Errorf("http get %s: %v", infoUrl, err)}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {return nil, 0, fmt.Errorf("http get %s: status %s!", infoUrl, resp.Status)}
+
+	info = &RenewalInfo{}
+	err = json.NewDecoder(resp.Body).Decode(info)
+	if err != nil {return nil, 0, fmt.Errorf("json decode renewalInfo: %v", err)}
+	if info.SuggestedWindow.End.Before(info.SuggestedWindow.Start) {
+		return nil, 0, fmt.Errorf("renewalInfo: window end is before start!")
+	}
+
+	retry = DefaultAriRetry
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err == nil && secs > 0 {retry = time.Duration(secs) * time.Second}
+	return info, retry, nil
+}
+
+// function that selects a random renewal time in the suggested window
+// a window that has passed results in an immediate renewal
+func AriRenewAt(start time.Time, end time.Time, now time.Time) (renewAt time.Time) {
+
+	if !end.After(now) {return now}
+	if start.Before(now) {start = now}
+	span := end.Sub(start)
+	if span <= 0 {return start}
+	return start.Add(time.Duration(mrand.Int63n(int64(span))))
+}
+
+// function that fetches the renewal window of a certificate and saves it in the meta data
+func UpdateCertAri(meta *CertMeta, hc *http.Client, dirUrl string, cert *x509.Certificate) (err error) {
+
+	certId, err := AriCertId(cert)
+	if err != nil {return fmt.Errorf("AriCertId: %v", err)}
+
+	info, retry, err := FetchRenewalInfo(hc, dirUrl, certId)
+	if err != nil {
+		// avoid a request on every scan if the CA has no ARI
+		meta.AriNext = time.Now().Add(DefaultAriRetry)
+		return fmt.Errorf("FetchRenewalInfo: %v", err)
+	}
+
+	meta.AriId = certId
+	meta.AriStart = info.SuggestedWindow.Start
+	meta.AriEnd = info.SuggestedWindow.End
+	meta.AriExplain = info.ExplanationURL
+	meta.AriNext = time.Now().Add(retry)
+	return nil
+}
+
+// function that refreshes the renewal window of a certificate in the cert directory
+// the window is only requested again after the retry time of the last request
+func RefreshCertAri(certDir string, certNam string, now time.Time) (updated bool, err error) {
+
+	metaFilnam := CertMetaFilnam(certDir, certNam)
+	meta, err := ReadCertMeta(metaFilnam)
+	if err != nil {return false, fmt.Errorf("ReadCertMeta: %v", err)}
+	if now.Before(meta.AriNext) {return false, nil}
+
+	leaf, err := ReadLeafCert(certDir + "/" + certNam + ".crt")
+	if err != nil {return false, fmt.Errorf("ReadLeafCert: %v", err)}
+	if IsRevoked(meta, leaf) {return false, nil}
+
+	le, err := ReadLEObj(meta.Account)
+	if err != nil {return false, fmt.Errorf("ReadLEObj: %v", err)}
+
+	prof, err := AcntCAProfile(le)
+	if err != nil {return false, fmt.Errorf("AcntCAProfile: %v", err)}
+	hc, err := CAHttpClient(prof)
+	if err != nil {return false, fmt.Errorf("CAHttpClient: %v", err)}
+
+	ariErr := UpdateCertAri(meta, hc, prof.DirUrl, leaf)
+
+	err = WriteCertMeta(metaFilnam, meta)
+	if err != nil {return false, fmt.Errorf("WriteCertMeta: %v", err)}
+	if ariErr != nil {return false, ariErr}
+	return true, nil
+}
+
+//
+// new order with the replaces field
+//
+
+// function that creates an order; if replaces holds the ARI id of a certificate and the CA supports ARI,
+// the order is sent with the replaces field
+// acme.Client.AuthorizeOrder has no replaces field, so the request is signed here
+func AuthorizeOrderAri(ctx context.Context, client *acme.Client, ids []acme.AuthzID, replaces string) (order *acme.Order, err error) {
+
+	if len(replaces) == 0 {return client.AuthorizeOrder(ctx, ids)}
+
+	hc := httpClient(client)
+	dir, err := fetchAcmeDir(hc, client.DirectoryURL)
+	if err != nil {return nil, fmt.Errorf("fetchAcmeDir: %v", err)}
+	if len(dir.RenewalInfo) == 0 {return client.AuthorizeOrder(ctx, ids)}
+
+	kid, err := acntKid(ctx, client)
+	if err != nil {return nil, fmt.Errorf("acntKid: %v", err)}
+
+	type orderId struct {
+		Type string `json:"type"`
+		Value string `json:"value"`
+	}
+	req := struct {
+		Identifiers []orderId `json:"identifiers"`
+		Replaces string `json:"replaces"`
+	}{Replaces: replaces}
+	for _, id := range ids {
+		req.Identifiers = append(req.Identifiers, orderId{Type: id.Type, Value: id.Value})
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {return nil, fmt.Errorf("json marshal order: %v", err)}
+
+	hdr, _, err := postJws(ctx, client, dir, kid, dir.NewOrder, payload, http.StatusCreated)
+	if err != nil {return nil, fmt.Errorf("newOrder: %v", err)}
+
+	orderUrl := hdr.Get("Location")
+	if len(orderUrl) == 0 {return nil, fmt.Errorf("newOrder: no order url!")}
+
+	order, err = client.GetOrder(ctx, orderUrl)
+	if err != nil {return nil, fmt.Errorf("client.GetOrder: %v", err)}
+	// GetOrder takes the url from the Location header, which the order response does not have
+	if len(order.URI) == 0 {order.URI = orderUrl}
+	return order, nil
+}
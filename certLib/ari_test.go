@@ -0,0 +1,228 @@
+// ari_test.go
+// tests of the ARI support against a mock acme directory that exposes renewalInfo
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// mock acme directory with the renewalInfo and newOrder endpoints
+type testAcmeDir struct {
+	mu sync.Mutex
+	srv *httptest.Server
+	// window and Retry-After of the renewalInfo endpoint
+	window AriWindow
+	retryAfter string
+	noAri bool
+	infoIds []string
+	// payloads of the newOrder requests
+	orders []map[string]interface{}
+}
+
+func newTestAcmeDir(t *testing.T) (ad *testAcmeDir) {
+
+	ad = &testAcmeDir{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dir", ad.directory)
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce-" + fmt.Sprint(time.Now().UnixNano()))
+	})
+	mux.HandleFunc("/renewal-info/", ad.renewalInfo)
+	mux.HandleFunc("/new-order", ad.newOrder)
+	mux.HandleFunc("/order/1", ad.order)
+	ad.srv = httptest.NewServer(mux)
+	t.Cleanup(ad.srv.Close)
+	return ad
+}
+
+func (ad *testAcmeDir) directory(w http.ResponseWriter, r *http.Request) {
+
+	dir := map[string]string{
+		"newNonce": ad.srv.URL + "/nonce",
+		"newAccount": ad.srv.URL + "/new-acct",
+		"newOrder": ad.srv.URL + "/new-order",
+		"revokeCert": ad.srv.URL + "/revoke",
+		"keyChange": ad.srv.URL + "/key-change",
+	}
+	if !ad.noAri {dir["renewalInfo"] = ad.srv.URL + "/renewal-info/"}
+	json.NewEncoder(w).Encode(dir)
+}
+
+func (ad *testAcmeDir) renewalInfo(w http.ResponseWriter, r *http.Request) {
+
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+	ad.infoIds = append(ad.infoIds, strings.TrimPrefix(r.URL.Path, "/renewal-info/"))
+	if len(ad.retryAfter) > 0 {w.Header().Set("Retry-After", ad.retryAfter)}
+	json.NewEncoder(w).Encode(RenewalInfo{SuggestedWindow: ad.window, ExplanationURL: "https://ca.example/why"})
+}
+
+// function that returns the decoded payload of a jws request
+func jwsPayload(r *http.Request) (payload []byte, err error) {
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {return nil, err}
+	jws := struct {
+		Payload string `json:"payload"`
+	}{}
+	err = json.Unmarshal(body, &jws)
+	if err != nil {return nil, err}
+	return base64.RawURLEncoding.DecodeString(jws.Payload)
+}
+
+func (ad *testAcmeDir) newOrder(w http.ResponseWriter, r *http.Request) {
+
+	payload, err := jwsPayload(r)
+	req := map[string]interface{}{}
+	if err == nil {err = json.Unmarshal(payload, &req)}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ad.mu.Lock()
+	ad.orders = append(ad.orders, req)
+	ad.mu.Unlock()
+
+	w.Header().Set("Replay-Nonce", "nonce-order")
+	w.Header().Set("Location", ad.srv.URL + "/order/1")
+	w.WriteHeader(http.StatusCreated)
+	ad.writeOrder(w)
+}
+
+func (ad *testAcmeDir) order(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", "nonce-get")
+	ad.writeOrder(w)
+}
+
+func (ad *testAcmeDir) writeOrder(w http.ResponseWriter) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "pending",
+		"identifiers": []map[string]string{{"type": "dns", "value": "example.com"}},
+		"authorizations": []string{ad.srv.URL + "/authz/1"},
+		"finalize": ad.srv.URL + "/order/1/finalize",
+	})
+}
+
+func TestAriCertId(t *testing.T) {
+
+	// test vector of RFC 9773 appendix A
+	aki := []byte{0x69, 0x88, 0x5B, 0x6B, 0x87, 0x46, 0x40, 0x41, 0xE1, 0xB3, 0x7B, 0x84, 0x7B, 0xA0, 0xAE, 0x2C, 0xDE, 0x01, 0xC8, 0xD4}
+	cert := &x509.Certificate{AuthorityKeyId: aki, SerialNumber: big.NewInt(0x87654321)}
+
+	certId, err := AriCertId(cert)
+	if err != nil {t.Fatalf("AriCertId: %v", err)}
+	if certId != "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" {t.Fatalf("AriCertId: got %s", certId)}
+
+	_, err = AriCertId(&x509.Certificate{SerialNumber: big.NewInt(1)})
+	if err == nil {t.Fatalf("AriCertId without authority key id: no error")}
+}
+
+func TestFetchRenewalInfo(t *testing.T) {
+
+	ad := newTestAcmeDir(t)
+	start := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	ad.window = AriWindow{Start: start, End: start.Add(48 * time.Hour)}
+	ad.retryAfter = "3600"
+
+	info, retry, err := FetchRenewalInfo(nil, ad.srv.URL + "/dir", "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE")
+	if err != nil {t.Fatalf("FetchRenewalInfo: %v", err)}
+	if !info.SuggestedWindow.Start.Equal(ad.window.Start) || !info.SuggestedWindow.End.Equal(ad.window.End) {
+		t.Fatalf("FetchRenewalInfo: window %v, want %v", info.SuggestedWindow, ad.window)
+	}
+	if retry != time.Hour {t.Fatalf("FetchRenewalInfo: retry %v, want 1h", retry)}
+	if len(ad.infoIds) != 1 || ad.infoIds[0] != "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" {t.Fatalf("renewalInfo requests: %v", ad.infoIds)}
+
+	// no Retry-After header
+	ad.retryAfter = ""
+	_, retry, err = FetchRenewalInfo(nil, ad.srv.URL + "/dir", "id")
+	if err != nil || retry != DefaultAriRetry {t.Fatalf("FetchRenewalInfo without Retry-After: retry %v %v", retry, err)}
+
+	// a window that ends before it starts is refused
+	ad.window = AriWindow{Start: start, End: start.Add(-time.Hour)}
+	_, _, err = FetchRenewalInfo(nil, ad.srv.URL + "/dir", "id")
+	if err == nil {t.Fatalf("FetchRenewalInfo with bad window: no error")}
+
+	// a CA without ARI
+	ad.noAri = true
+	_, _, err = FetchRenewalInfo(nil, ad.srv.URL + "/dir", "id")
+	if err == nil {t.Fatalf("FetchRenewalInfo without renewalInfo: no error")}
+}
+
+func TestAriRenewAt(t *testing.T) {
+
+	now := time.Now()
+
+	// the window has passed
+	renewAt := AriRenewAt(now.Add(-48 * time.Hour), now.Add(-time.Hour), now)
+	if !renewAt.Equal(now) {t.Fatalf("passed window: got %v, want now", renewAt)}
+
+	// the window lies ahead
+	start := now.Add(24 * time.Hour)
+	end := now.Add(48 * time.Hour)
+	for i:=0; i< 100; i++ {
+		renewAt = AriRenewAt(start, end, now)
+		if renewAt.Before(start) || !renewAt.Before(end) {t.Fatalf("future window: %v not in [%v, %v)", renewAt, start, end)}
+	}
+
+	// the window has started
+	start = now.Add(-24 * time.Hour)
+	for i:=0; i< 100; i++ {
+		renewAt = AriRenewAt(start, end, now)
+		if renewAt.Before(now) || !renewAt.Before(end) {t.Fatalf("open window: %v not in [%v, %v)", renewAt, now, end)}
+	}
+
+	// an empty window
+	renewAt = AriRenewAt(end, end, now)
+	if !renewAt.Equal(end) {t.Fatalf("empty window: got %v, want %v", renewAt, end)}
+}
+
+func TestAuthorizeOrderAri(t *testing.T) {
+
+	ad := newTestAcmeDir(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {t.Fatalf("GenerateKey: %v", err)}
+	client := &acme.Client{
+		Key: key,
+		KID: acme.KeyID(ad.srv.URL + "/acct/1"),
+		DirectoryURL: ad.srv.URL + "/dir",
+	}
+
+	ids := []acme.AuthzID{{Type: "dns", Value: "example.com"}}
+	order, err := AuthorizeOrderAri(context.Background(), client, ids, "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE")
+	if err != nil {t.Fatalf("AuthorizeOrderAri: %v", err)}
+	if order.URI != ad.srv.URL + "/order/1" || order.Status != acme.StatusPending {t.Fatalf("AuthorizeOrderAri: got order %s %s", order.URI, order.Status)}
+
+	if len(ad.orders) != 1 {t.Fatalf("newOrder requests: got %d, want 1", len(ad.orders))}
+	if ad.orders[0]["replaces"] != "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" {t.Fatalf("replaces: got %v", ad.orders[0]["replaces"])}
+	idList, _ := ad.orders[0]["identifiers"].([]interface{})
+	if len(idList) != 1 {t.Fatalf("identifiers: got %v", ad.orders[0]["identifiers"])}
+
+	// without renewalInfo the order is sent without the replaces field
+	ad.noAri = true
+	_, err = AuthorizeOrderAri(context.Background(), client, ids, "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE")
+	if err != nil {t.Fatalf("AuthorizeOrderAri without ari: %v", err)}
+	if len(ad.orders) != 2 {t.Fatalf("newOrder requests: got %d, want 2", len(ad.orders))}
+	if _, ok := ad.orders[1]["replaces"]; ok {t.Fatalf("replaces sent to a CA without ari")}
+}
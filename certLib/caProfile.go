@@ -0,0 +1,210 @@
+// caProfile.go
+// named profiles of acme CAs
+// an account selects a profile with caProfile; the profiles are built in or read from LEAcnt/account/caProfiles.yaml
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+
+	yaml "github.com/goccy/go-yaml"
+	"golang.org/x/crypto/acme"
+)
+
+type CAProfile struct {
+	Name string `yaml:"name"`
+	DirUrl string `yaml:"dirUrl"`
+	// external account binding of the CA; the values of the account file take precedence
+	EabKid string `yaml:"eabKid"`
+	EabHmac string `yaml:"eabHmac"`
+	// pem file with the root certificates that verify the tls connection to the acme server
+	RootCAFil string `yaml:"rootCA"`
+	// common name of the issuer of the top certificate of the preferred chain
+	PreferredChain string `yaml:"preferredChain"`
+}
+
+// content of the file caProfiles.yaml
+type CAProfileList struct {
+	Profiles []CAProfile `yaml:"profiles"`
+}
+
+// profiles that are available without profile file
+// zerossl requires the eab values of the zerossl account
+var builtinProfiles = []CAProfile{
+	{Name: "letsencrypt-staging", DirUrl: "https://acme-staging-v02.api.letsencrypt.org/directory"},
+	{Name: "letsencrypt", DirUrl: "https://acme-v02.api.letsencrypt.org/directory"},
+	{Name: "zerossl", DirUrl: "https://acme.zerossl.com/v2/DV90"},
+	{Name: "buypass-test", DirUrl: "https://api.test4.buypass.no/acme/directory"},
+	{Name: "buypass", DirUrl: "https://api.buypass.com/acme/directory"},
+	{Name: "google", DirUrl: "https://dv.acme-v02.api.pki.goog/directory"},
+}
+
+// function that returns the name of the profile file
+func CAProfileFilnam() (filnam string, err error) {
+
+	LEDir, err := GetCertDir("LEAcnt")
+	if err != nil {return "", fmt.Errorf("GetCertDir: %v", err)}
+	return LEDir + "caProfiles.yaml", nil
+}
+
+// function that returns the built-in profiles and the profiles of the profile file
+// a profile of the file replaces a built-in profile with the same name; a missing file is not an error
+func ReadCAProfiles(filnam string) (profs []CAProfile, err error) {
+
+	profs = append(profs, builtinProfiles...)
+
+	bytData, err := os.ReadFile(filnam)
+	if err != nil {
+		if os.IsNotExist(err) {return profs, nil}
+		return nil, fmt.Errorf("os.ReadFile: %v", err)
+	}
+
+	profList := CAProfileList{}
+	err = yaml.Unmarshal(bytData, &profList)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+
+	for _, prof := range profList.Profiles {
+		if len(prof.Name) == 0 {return nil, fmt.Errorf("profile without name in %s!", filnam)}
+		if len(prof.DirUrl) == 0 {return nil, fmt.Errorf("profile %s has no dirUrl!", prof.Name)}
+		found := false
+		for i:=0; i< len(profs); i++ {
+			if profs[i].Name == prof.Name {
+				profs[i] = prof
+				found = true
+				break
+			}
+		}
+		if !found {profs = append(profs, prof)}
+	}
+	return profs, nil
+}
+
+// function that returns the profile profNam
+func GetCAProfile(profNam string) (prof *CAProfile, err error) {
+
+	profFilnam, err := CAProfileFilnam()
+	if err != nil {return nil, fmt.Errorf("CAProfileFilnam: %v", err)}
+
+	profs, err := ReadCAProfiles(profFilnam)
+	if err != nil {return nil, fmt.Errorf("ReadCAProfiles: %v", err)}
+
+	for i:=0; i< len(profs); i++ {
+		if profs[i].Name == profNam {return &profs[i], nil}
+	}
+	return nil, fmt.Errorf("no CA profile %s!", profNam)
+}
+
+// function that returns the CA profile of an account
+// an account without caProfile uses TestUrl or ProdUrl, depending on useProd
+func AcntCAProfile(le *LEObj) (prof *CAProfile, err error) {
+
+	if len(le.Profile) == 0 {
+		prof = &CAProfile{Name: "test", DirUrl: le.TestUrl}
+		if le.UseProd {prof = &CAProfile{Name: "prod", DirUrl: le.ProdUrl}}
+	} else {
+		prof, err = GetCAProfile(le.Profile)
+		if err != nil {return nil, err}
+	}
+
+	if len(le.EabKid) > 0 {
+		prof.EabKid = le.EabKid
+		prof.EabHmac = le.EabHmac
+	}
+	if len(prof.DirUrl) == 0 {return nil, fmt.Errorf("CA profile %s has no directory url!", prof.Name)}
+	return prof, nil
+}
+
+// function that returns the http client for the acme server of a profile
+// a profile without root CA file returns nil, which selects the default client
+func CAHttpClient(prof *CAProfile) (hc *http.Client, err error) {
+
+	if len(prof.RootCAFil) == 0 {return nil, nil}
+
+	pemData, err := os.ReadFile(prof.RootCAFil)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {return nil, fmt.Errorf("no certificates in %s!", prof.RootCAFil)}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	hc = &http.Client{Transport: transport}
+	return hc, nil
+}
+
+var linkAltRegex = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?alternate"?`)
+
+// function that fetches a certificate chain and the urls of its alternate chains
+func fetchCertChain(ctx context.Context, client *acme.Client, dir *acmeDirRaw, kid string, certUrl string) (derCerts [][]byte, altUrls []string, err error) {
+
+	hdr, pemData, err := postJws(ctx, client, dir, kid, certUrl, nil, http.StatusOK)
+	if err != nil {return nil, nil, err}
+
+	for {
+		block, rest := pem.Decode(pemData)
+		if block == nil {break}
+		if block.Type == "CERTIFICATE" {derCerts = append(derCerts, block.Bytes)}
+		pemData = rest
+	}
+	if len(derCerts) == 0 {return nil, nil, fmt.Errorf("no certificates at %s!", certUrl)}
+
+	for _, link := range hdr.Values("Link") {
+		for _, match := range linkAltRegex.FindAllStringSubmatch(link, -1) {
+			altUrls = append(altUrls, match[1])
+		}
+	}
+	return derCerts, altUrls, nil
+}
+
+// function that tests whether the issuer of the top certificate of a chain has the common name issuerCN
+func chainIssuedBy(derCerts [][]byte, issuerCN string) (ok bool) {
+
+	top, err := x509.ParseCertificate(derCerts[len(derCerts)-1])
+	if err != nil {return false}
+	return top.Issuer.CommonName == issuerCN || top.Subject.CommonName == issuerCN
+}
+
+// function that returns the chain of the certificate at certUrl whose top certificate is issued by preferred
+// if no chain matches, the default chain derCerts is returned
+func FetchPreferredChain(ctx context.Context, client *acme.Client, certUrl string, preferred string, derCerts [][]byte) (chain [][]byte, err error) {
+
+	if len(preferred) == 0 || chainIssuedBy(derCerts, preferred) {return derCerts, nil}
+
+	dir, err := fetchAcmeDir(httpClient(client), client.DirectoryURL)
+	if err != nil {return nil, fmt.Errorf("fetchAcmeDir: %v", err)}
+	kid, err := acntKid(ctx, client)
+	if err != nil {return nil, fmt.Errorf("acntKid: %v", err)}
+
+	_, altUrls, err := fetchCertChain(ctx, client, dir, kid, certUrl)
+	if err != nil {return nil, fmt.Errorf("fetchCertChain: %v", err)}
+
+	for _, altUrl := range altUrls {
+		altCerts, _, err := fetchCertChain(ctx, client, dir, kid, altUrl)
+		if err != nil {return nil, fmt.Errorf("fetchCertChain alternate: %v", err)}
+		if chainIssuedBy(altCerts, preferred) {return altCerts, nil}
+	}
+	log.Printf("no chain issued by %s -- using the default chain\n", preferred)
+	return derCerts, nil
+}
+
+func PrintCAProfile(prof *CAProfile) {
+
+	fmt.Printf("*************** CA Profile: %s ***************\n", prof.Name)
+	fmt.Printf("dir url:    %s\n", prof.DirUrl)
+	if len(prof.EabKid) > 0 {fmt.Printf("eab kid:    %s\n", prof.EabKid)}
+	if len(prof.RootCAFil) > 0 {fmt.Printf("root CA:    %s\n", prof.RootCAFil)}
+	if len(prof.PreferredChain) > 0 {fmt.Printf("pref chain: %s\n", prof.PreferredChain)}
+	fmt.Printf("************* End CA Profile *****************\n")
+}
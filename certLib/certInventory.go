@@ -0,0 +1,246 @@
+// certInventory.go
+// inventory of the issued certificates in an embedded key-value store (bbolt)
+// the inventory is the file LEAcnt/inventory/certs.db; the records are CertList values encoded as yaml
+// bucket certs holds the current certificate of each certificate name; bucket issued holds every issued certificate by issuer and serial (ARI cert id, else issuer DN and serial)
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	invCertBucket = "certs"
+	invIssuedBucket = "issued"
+)
+
+type CertInventory struct {
+	Filnam string
+	db *bolt.DB
+}
+
+// filter of ListCerts; empty fields do not filter
+type CertFilter struct {
+	// all issued certificates instead of the current certificate of each name
+	All bool
+	// certificates that expire within ExpireDays days, including expired certificates; 0: no filter
+	ExpireDays int
+	// certificates with a SAN equal to Domain or a subdomain of Domain
+	Domain string
+	Account string
+	CertNam string
+}
+
+// function that opens the inventory and creates it if it does not exist
+// a second program waits up to 10 seconds for the inventory
+func OpenCertInventory(invFilnam string) (inv *CertInventory, err error) {
+
+	err = os.MkdirAll(filepath.Dir(invFilnam), 0755)
+	if err != nil {return nil, fmt.Errorf("os.MkdirAll: %v", err)}
+
+	db, err := bolt.Open(invFilnam, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {return nil, fmt.Errorf("bolt.Open %s: %v", invFilnam, err)}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{invCertBucket, invIssuedBucket} {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {return err}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create buckets: %v", err)
+	}
+	return &CertInventory{Filnam: invFilnam, db: db}, nil
+}
+
+func (inv *CertInventory) Close() (err error) {
+	return inv.db.Close()
+}
+
+// function that adds a certificate to the inventory
+// the certificate replaces the current certificate of its name unless the current certificate is newer
+func (inv *CertInventory) AddCert(rec *CertList) (err error) {
+
+	if len(rec.CertNam) == 0 {return fmt.Errorf("no cert name!")}
+	if len(rec.CertId) == 0 {return fmt.Errorf("cert %s has no cert id!", rec.CertNam)}
+
+	recByt, err := yaml.Marshal(rec)
+	if err != nil {return fmt.Errorf("yaml Marshal: %v", err)}
+
+	err = inv.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket([]byte(invIssuedBucket)).Put([]byte(rec.CertId), recByt)
+		if err != nil {return err}
+
+		certBucket := tx.Bucket([]byte(invCertBucket))
+		if curByt := certBucket.Get([]byte(rec.CertNam)); curByt != nil {
+			cur := CertList{}
+			if yaml.Unmarshal(curByt, &cur) == nil && cur.Valid.After(rec.Valid) {return nil}
+		}
+		return certBucket.Put([]byte(rec.CertNam), recByt)
+	})
+	if err != nil {return fmt.Errorf("db Update: %v", err)}
+	return nil
+}
+
+// function that returns the certificates of the inventory that pass the filter, ordered by expiry
+func (inv *CertInventory) ListCerts(filter *CertFilter) (recs []CertList, err error) {
+
+	if filter == nil {filter = &CertFilter{}}
+	bucket := invCertBucket
+	if filter.All {bucket = invIssuedBucket}
+	expLimit := time.Now().AddDate(0, 0, filter.ExpireDays)
+
+	err = inv.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).ForEach(func(key, val []byte) error {
+			rec := CertList{}
+			err := yaml.Unmarshal(val, &rec)
+			if err != nil {return fmt.Errorf("record %s: %v", string(key), err)}
+			if filter.match(&rec, expLimit) {recs = append(recs, rec)}
+			return nil
+		})
+	})
+	if err != nil {return nil, fmt.Errorf("db View: %v", err)}
+
+	sort.Slice(recs, func(i, j int) bool {return recs[i].Expire.Before(recs[j].Expire)})
+	return recs, nil
+}
+
+func (filter *CertFilter) match(rec *CertList, expLimit time.Time) (ok bool) {
+
+	if filter.ExpireDays > 0 && rec.Expire.After(expLimit) {return false}
+	if len(filter.Account) > 0 && rec.Account != filter.Account {return false}
+	if len(filter.CertNam) > 0 && rec.CertNam != filter.CertNam {return false}
+	if len(filter.Domain) > 0 {
+		found := false
+		for _, dom := range rec.Domains {
+			if dom == filter.Domain || strings.HasSuffix(dom, "." + filter.Domain) {found = true}
+		}
+		if !found {return false}
+	}
+	return true
+}
+
+// function that creates the inventory record of a certificate from its certificate chain
+// the caller adds the CA, account, file and url fields
+func NewCertRec(certNam string, derCerts [][]byte) (rec *CertList, err error) {
+
+	if len(derCerts) == 0 {return nil, fmt.Errorf("no certificates!")}
+	leaf, err := x509.ParseCertificate(derCerts[0])
+	if err != nil {return nil, fmt.Errorf("x509.ParseCertificate: %v", err)}
+
+	keyType, err := PubKeyType(leaf.PublicKey)
+	if err != nil {return nil, fmt.Errorf("PubKeyType: %v", err)}
+
+	rec = &CertList{
+		CertNam: certNam,
+		Domains: leaf.DNSNames,
+		Valid: leaf.NotBefore,
+		Expire: leaf.NotAfter,
+		Issuer: leaf.Issuer.CommonName,
+		Serial: leaf.SerialNumber.Text(16),
+		CertId: invCertId(leaf),
+		KeyType: keyType,
+		Issued: time.Now(),
+	}
+	return rec, nil
+}
+
+// function that returns the key of a certificate in bucket issued
+// the ARI cert id; a certificate without authority key id, e.g. of a private CA, is keyed by issuer DN and serial
+func invCertId(cert *x509.Certificate) (certId string) {
+
+	certId, err := AriCertId(cert)
+	if err == nil {return certId}
+	return cert.Issuer.String() + "." + cert.SerialNumber.Text(16)
+}
+
+// function that adds the fields of the meta file of a certificate to its inventory record
+func (rec *CertList) AddMeta(meta *CertMeta) {
+
+	rec.Account = meta.Account
+	rec.LEUrl = meta.CAUrl
+	rec.CAProfile = meta.CAProfile
+	rec.OrderUrl = meta.OrderUrl
+	rec.CertUrl = meta.CertUrl
+	if len(meta.KeyId) > 0 {rec.KeyFil = meta.KeyId}
+	if !meta.Issued.IsZero() {rec.Issued = meta.Issued}
+}
+
+// function that opens the inventory, adds a certificate and closes the inventory
+func RecordCert(invFilnam string, rec *CertList) (err error) {
+
+	inv, err := OpenCertInventory(invFilnam)
+	if err != nil {return err}
+	defer inv.Close()
+
+	return inv.AddCert(rec)
+}
+
+// function that creates the inventory records of the certificates in the cert directory
+// the fields of the meta file of a certificate are added if it has one
+// a certificate that cannot be read is logged and skipped
+func ScanCertDir(certDir string) (recs []CertList, err error) {
+
+	certNames, err := ListCertNames(certDir)
+	if err != nil {return nil, fmt.Errorf("ListCertNames: %v", err)}
+
+	for _, certNam := range certNames {
+		certFilnam := certDir + "/" + certNam + ".crt"
+		leaf, err := ReadLeafCert(certFilnam)
+		if err != nil {
+			log.Printf("ScanCertDir: skipping cert %s: ReadLeafCert: %v\n", certNam, err)
+			continue
+		}
+
+		rec, err := NewCertRec(certNam, [][]byte{leaf.Raw})
+		if err != nil {
+			log.Printf("ScanCertDir: skipping cert %s: %v\n", certNam, err)
+			continue
+		}
+		rec.CertFil = certFilnam
+		rec.KeyFil = certDir + "/" + certNam + ".key"
+		rec.Issued = time.Time{}
+
+		meta, err := ReadCertMeta(CertMetaFilnam(certDir, certNam))
+		if err == nil {rec.AddMeta(meta)}
+		recs = append(recs, *rec)
+	}
+	return recs, nil
+}
+
+func PrintCertRec(rec *CertList) {
+
+	fmt.Printf("*************** Cert: %s ***************\n", rec.CertNam)
+	fmt.Printf("domains:    %v\n", rec.Domains)
+	fmt.Printf("issuer:     %s\n", rec.Issuer)
+	fmt.Printf("CA url:     %s\n", rec.LEUrl)
+	if len(rec.CAProfile) > 0 {fmt.Printf("CA profile: %s\n", rec.CAProfile)}
+	fmt.Printf("account:    %s\n", rec.Account)
+	fmt.Printf("serial:     %s\n", rec.Serial)
+	fmt.Printf("cert id:    %s\n", rec.CertId)
+	fmt.Printf("key type:   %s\n", rec.KeyType)
+	fmt.Printf("not before: %s\n", rec.Valid.Format(time.RFC1123))
+	fmt.Printf("not after:  %s\n", rec.Expire.Format(time.RFC1123))
+	fmt.Printf("cert file:  %s\n", rec.CertFil)
+	fmt.Printf("key file:   %s\n", rec.KeyFil)
+	fmt.Printf("order url:  %s\n", rec.OrderUrl)
+	fmt.Printf("cert url:   %s\n", rec.CertUrl)
+	if !rec.Issued.IsZero() {fmt.Printf("issued:     %s\n", rec.Issued.Format(time.RFC1123))}
+	fmt.Printf("************* End Cert *****************\n")
+}
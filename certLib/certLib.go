@@ -14,9 +14,12 @@ import (
     "time"
     "context"
 	"strings"
+    "crypto"
     "crypto/ecdsa"
+    "crypto/ed25519"
     "crypto/elliptic"
     "crypto/rand"
+    "crypto/rsa"
     "crypto/x509"
     "crypto/x509/pkix"
     "encoding/asn1"
@@ -40,37 +43,83 @@ type LEObj struct {
 	UseProd bool `yaml:"useProd"`
 	TestUrl string `yaml:"TestUrl"`
 	ProdUrl string `yaml:"ProdUrl"`
+	// name of the CA profile; replaces TestUrl, ProdUrl and useProd
+	Profile string `yaml:"caProfile"`
+	DnsProvider string `yaml:"dnsProvider"`
+	// account status at the CA: valid or deactivated
+	Status string `yaml:"status"`
+	ContactsUpdated time.Time `yaml:"contactsUpdated"`
+	Deactivated time.Time `yaml:"deactivated"`
+	// external account binding: key id and base64url encoded hmac key provided by the CA
+	EabKid string `yaml:"eabKid"`
+	EabHmac string `yaml:"eabHmac"`
+	// account returned by the CA at registration
+	Account *JsAcnt `yaml:"acmeAccount"`
+	// key store of the account key: file (default) or pkcs11
+	// keyId is the label of the account key in the key store; the default is the account name
+	KeyStore string `yaml:"keyStore"`
+	KeyId string `yaml:"keyId"`
 }
 
 type CsrList struct {
     AcntName string `yaml:"account"`
-	LastLU time.Time `yaml:"last"`
-	OrderUrl string `yaml:"orderUrl"`
-	CertUrl string `yaml:"certUrl"`
+	// accounts tried in order if the issuance with the account fails
+	Fallback []string `yaml:"fallbackAccounts"`
+	DnsProvider string `yaml:"dnsProvider"`
+	Http01 Http01Cfg `yaml:"http01"`
+	TlsAlpn01 TlsAlpn01Cfg `yaml:"tlsAlpn01"`
+	Propagation PropCfg `yaml:"propagation"`
+	// if set, createCertsV3 issues an ecdsa and an rsa certificate
+	Dual *DualCfg `yaml:"dual"`
+	// key store of the certificate keys: file (default) or pkcs11
+	KeyStore string `yaml:"keyStore"`
     Domains []CsrDat `yaml:"domains"`
+	// runtime state; saved in the state file of the csr list (see csrState.go)
+	CsrRun `yaml:"-"`
 }
 
 type CsrDat struct {
     Domain string `yaml:"domain"`
     Email string `yaml:"email"`
     PemFil string `yaml:"pemfil"`
-	ChalRecId string `yaml:"chalrec"`
-	Token	string `yaml:"token"`
-	TokVal string `yaml:"tokval"`
-	TokUrl string `yaml:"tokUrl"`
-	TokIssue time.Time `yaml:"issue"`
-	TokExp time.Time `yaml:"expire"`
-	OrderUrl string `yaml:"orderUrl"`
-	CertUrl string `yaml:"certUrl"`
+	// challenge type: dns-01 (default), http-01 or tls-alpn-01
+	ChalType string `yaml:"chaltype"`
+	// key type of the certificate key: rsa2048, rsa3072, rsa4096, ec256 (default), ec384 or ed25519
+	KeyType string `yaml:"keytype"`
+	// key policy of the certificate key: new (default) or reuse
+	KeyPolicy string `yaml:"keypolicy"`
+	// age in days after which a reused key triggers a warning; default 365
+	KeyMaxAge int `yaml:"keymaxage"`
     Name pkixName `yaml:"Name"`
+	// runtime state; saved in the state file of the csr list
+	DomRun `yaml:"-"`
 }
 
+// record of an issued certificate in the certificate inventory (see certInventory.go)
 type CertList struct {
 	CertNam string `yaml:"certName"`
+	// SANs of the certificate
 	Domains []string `yaml:"domains"`
+	// directory url of the CA
 	LEUrl string	`yaml:"LEUrl"`
+	// NotBefore and NotAfter of the certificate
 	Valid	time.Time	`yaml:"valid"`
 	Expire time.Time	`yaml:"expire"`
+	// common name of the issuer
+	Issuer string `yaml:"issuer"`
+	CAProfile string `yaml:"caProfile"`
+	Account string `yaml:"account"`
+	// hex serial number of the certificate
+	Serial string `yaml:"serial"`
+	// ARI certificate id (authority key id "." serial), or issuer DN "." serial if the certificate has no authority key id
+	CertId string `yaml:"certId"`
+	KeyType string `yaml:"keyType"`
+	CertFil string `yaml:"certFile"`
+	// key file, or key label of a key store other than file
+	KeyFil string `yaml:"keyFile"`
+	OrderUrl string `yaml:"orderUrl"`
+	CertUrl string `yaml:"certUrl"`
+	Issued time.Time `yaml:"issued"`
 }
 
 
@@ -90,6 +139,12 @@ type certLibObj struct {
 	CfDir string
 	CsrDir string
 	CfApiFilnam string
+	Rfc2136Filnam string
+	ResponderFilnam string
+	ChalTokFilnam string
+	RenewFilnam string
+	RenewSchedFilnam string
+	InventoryFilnam string
 	ZoneFilnam string
 }
 
@@ -171,6 +226,13 @@ func InitCertLib()(certobj *certLibObj, err error) {
     if len(cfDir) == 0 {return nil, fmt.Errorf("could not resolve env var cfDir!")}
 	certObj.CfDir = cfDir
     certObj.CfApiFilnam = cfDir + "/token/cfDns.yaml"
+    certObj.Rfc2136Filnam = cfDir + "/token/rfc2136.yaml"
+
+	certObj.ResponderFilnam = leAcnt + "/responder/responder.yaml"
+	certObj.ChalTokFilnam = leAcnt + "/responder/chalToks.yaml"
+	certObj.RenewFilnam = leAcnt + "/renew/renew.yaml"
+	certObj.RenewSchedFilnam = leAcnt + "/renew/schedule.yaml"
+	certObj.InventoryFilnam = leAcnt + "/inventory/certs.db"
 
 	certObj.CsrDir = leAcnt+ "/csrList/"
 
@@ -202,11 +264,12 @@ func GetCertDir(acntNam string)(certDir string, err error) {
 	return certDir, nil
 }
 
-func GenCertKey()(certKey *ecdsa.PrivateKey,err error) {
+// function that generates a certificate key of the key type (see keyType.go)
+func GenCertKey(keyType string)(certKey crypto.Signer,err error) {
 
-    certKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    certKey, err = genKey(keyType)
     if err != nil {
-        return nil, fmt.Errorf("ecdsa.GenerateKey: %v\n",err)
+        return nil, fmt.Errorf("genKey %s: %v\n", keyType, err)
     }
 
 	return certKey, nil
@@ -231,6 +294,10 @@ func ReadCsrFil(inFilnam string)(csrDatList *CsrList, err error) {
         return nil, fmt.Errorf("yaml Unmarshal: %v\n", err)
     }
 
+	// the runtime fields are kept in the state file of the csr list
+	err = loadCsrState(inFilnam, bytData, csrList)
+	if err != nil {return nil, fmt.Errorf("loadCsrState: %v\n", err)}
+
     return csrList, nil
 }
 
@@ -244,12 +311,9 @@ func WriteCsrFil(outFilnam string, csrDatList *CsrList) (err error) {
 	idx := strings.Index(outFilnam, ".yaml")
 	if idx == -1 {outFilnam += ".yaml"}
 
-	outfil, err := os.Create(outFilnam)
-	if err!= nil {return fmt.Errorf("CreateFile: %v\n",err)}
-
-//	err = os.WriteFile(outFilnam, csrByte, 0666)
-	_, err = outfil.Write(csrByte)
-	if err!= nil {return fmt.Errorf("WriteFile: %v\n",err)}
+	// the runtime fields are not written; see SaveCsrState
+	err = writeFileAtomic(outFilnam, csrByte, 0644)
+	if err!= nil {return fmt.Errorf("writeFileAtomic: %v\n",err)}
 	return nil
 }
 
@@ -285,15 +349,12 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
 	if len(leAcnt.AcntNam) < 1 {return nil, fmt.Errorf("no AcntName provided!\n")}
 
 	remove := leAcnt.Remove
-	useProd := leAcnt.UseProd
 
-	LeUrl :=""
-	if useProd {
-		LeUrl = leAcnt.ProdUrl
-	} else {
-		LeUrl = leAcnt.TestUrl
-//		LeUrl = "https://acme-staging-v02.api.letsencrypt.org/directory"
-	}
+	prof, err := AcntCAProfile(&leAcnt)
+	if err != nil {return nil, fmt.Errorf("AcntCAProfile: %v", err)}
+	LeUrl := prof.DirUrl
+	caHttpClient, err := CAHttpClient(prof)
+	if err != nil {return nil, fmt.Errorf("CAHttpClient: %v", err)}
 
 	if dbg {PrintLEAcnt(&leAcnt)}
 
@@ -306,6 +367,12 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
 	leAcnt.PrivKeyFilnam = privFilnam
 	leAcnt.PubKeyFilnam = pubFilnam
 
+	useKeyStore := !IsFileKeyStore(leAcnt.KeyStore)
+	if useKeyStore {
+		leAcnt.PrivKeyFilnam = ""
+		leAcnt.PubKeyFilnam = ""
+	}
+
 	_, err = os.Stat(privFilnam)
 	if err == nil {
 		if remove {
@@ -328,8 +395,16 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
 		}
 	}
 
-    akey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-    if err != nil { return nil, fmt.Errorf("Generate Key: %v", err)}
+    var akey crypto.Signer
+	if useKeyStore {
+		ks, err := NewKeyStore(leAcnt.KeyStore)
+		if err != nil {return nil, fmt.Errorf("NewKeyStore: %v", err)}
+		akey, err = ks.GenerateKey(AcntKeyId(&leAcnt), KeyEc256)
+		if err != nil {return nil, fmt.Errorf("key store %s: GenerateKey: %v", ks.Name(), err)}
+	} else {
+		akey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil { return nil, fmt.Errorf("Generate Key: %v", err)}
+	}
 
     if dbg {log.Printf("newClient: key generated!\n")}
 
@@ -337,6 +412,7 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
     client := &acme.Client{
 		Key: akey,
 		DirectoryURL: LeUrl,
+		HTTPClient: caHttpClient,
 		}
 
     if dbg {
@@ -349,6 +425,13 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
 		Contact: leAcnt.Contacts,
 	}
 
+	eab, err := GetEab(prof.EabKid, prof.EabHmac)
+	if err != nil {return nil, fmt.Errorf("GetEab: %v", err)}
+	if eab != nil {
+		acntTpl.ExternalAccountBinding = eab
+		log.Printf("registering with external account binding: %s\n", eab.KID)
+	}
+
     acnt, err := client.Register(ctx, &acntTpl, acme.AcceptTOS)
     if err != nil { return nil, fmt.Errorf("client.Register: %v", err)}
 
@@ -361,34 +444,31 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
 		PrintAccount(acnt)
 	}
 
-	privateKey := (client.Key).(*ecdsa.PrivateKey)
-
-    publicKey := &ecdsa.PublicKey{}
-
-    publicKey = &privateKey.PublicKey
-
-    x509Encoded, err := x509.MarshalECPrivateKey(privateKey)
-    if err != nil {return nil, fmt.Errorf("x509.MarshalECPrivateKey: %v", err)}
-
-    pemEncoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
+	if useKeyStore {
+		log.Printf("account key %s is kept in key store %s\n", AcntKeyId(&leAcnt), leAcnt.KeyStore)
+	} else {
+		privateKey := (client.Key).(*ecdsa.PrivateKey)
 
-    err = os.WriteFile(privFilnam, pemEncoded, 0644)
-    if err != nil {return nil, fmt.Errorf("pem priv key write file: %v", err)}
+		pemEncoded, pemEncodedPub, err := encodeAcntKey(privateKey)
+		if err != nil {return nil, fmt.Errorf("encodeAcntKey: %v", err)}
 
-    x509EncodedPub, err := x509.MarshalPKIXPublicKey(publicKey)
-    if err != nil {return nil, fmt.Errorf("x509.MarshalPKIXPublicKey: %v", err)}
+		err = os.WriteFile(privFilnam, pemEncoded, 0600)
+		if err != nil {return nil, fmt.Errorf("pem priv key write file: %v", err)}
 
-    pemEncodedPub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509EncodedPub})
-    err = os.WriteFile(pubFilnam, pemEncodedPub, 0644)
-    if err != nil {return nil, fmt.Errorf("pem pub key write file: %v", err)}
+		err = os.WriteFile(pubFilnam, pemEncodedPub, 0644)
+		if err != nil {return nil, fmt.Errorf("pem pub key write file: %v", err)}
+	}
 
 	leAcnt.Updated = time.Now()
 	leAcnt.AcntId = string(client.KID)
+	leAcnt.Account = NewJsAcnt(acnt)
+	// the CA does not return the binding; the hmac key is only kept in EabHmac
+	if eab != nil {leAcnt.Account.ExternalAccountBinding = &acme.ExternalAccountBinding{KID: eab.KID}}
 
     newAcntData, err := yaml.Marshal(&leAcnt)
     if err != nil {return nil, fmt.Errorf("yaml Unmarshal account file: %v\n", err)}
 
-	if err = os.WriteFile(acntFilnam, newAcntData, 0600); err != nil {
+	if err = writeFileAtomic(acntFilnam, newAcntData, 0600); err != nil {
         return nil, fmt.Errorf("Error writing key file %q: %v", acntFilnam, err)
     }
 
@@ -398,9 +478,8 @@ func CreateLEAccount(acntNam string, dbg bool) (le *LEObj, err error) {
 }
 
 
-func GetLEClient(acntNam string, dbg bool) (cl *acme.Client, err error) {
-
-	client :=acme.Client{}
+// function that reads the yaml account file
+func ReadLEObj(acntNam string) (le *LEObj, err error) {
 
 	// find LE folder
 	LEDir, err := GetCertDir("LEAcnt")
@@ -423,11 +502,61 @@ func GetLEClient(acntNam string, dbg bool) (cl *acme.Client, err error) {
 
     err = yaml.Unmarshal(acntData, &leAcnt)
     if err != nil {return nil, fmt.Errorf("yaml Unmarshal account file: %v\n", err)}
+
+	return &leAcnt, nil
+}
+
+// function that writes the yaml account file
+func WriteLEObj(acntNam string, le *LEObj) (err error) {
+
+	LEDir, err := GetCertDir("LEAcnt")
+	if err != nil {return fmt.Errorf("GetCertDir: %v", err)}
+
+	acntFilnam := LEDir + "LEAcnt.yaml"
+	if len(acntNam) > 0 {acntFilnam = LEDir + acntNam + ".yaml"}
+
+	acntData, err := yaml.Marshal(le)
+	if err != nil {return fmt.Errorf("yaml Marshal account file: %v", err)}
+
+	err = writeFileAtomic(acntFilnam, acntData, 0600)
+	if err != nil {return fmt.Errorf("writeFileAtomic: %v", err)}
+	return nil
+}
+
+func GetLEClient(acntNam string, dbg bool) (cl *acme.Client, err error) {
+
+	client :=acme.Client{}
+
+	le, err := ReadLEObj(acntNam)
+	if err != nil {return nil, fmt.Errorf("ReadLEObj: %v", err)}
+	leAcnt := *le
 	if dbg {PrintLEAcnt(&leAcnt)}
 
 	if len(leAcnt.AcntId) == 0 {
 		return nil, fmt.Errorf("no CA acount id found!\n")
 	}
+	if leAcnt.Status == acme.StatusDeactivated {
+		return nil, fmt.Errorf("account %s was deactivated!", leAcnt.AcntNam)
+	}
+
+	prof, err := AcntCAProfile(&leAcnt)
+	if err != nil {return nil, fmt.Errorf("AcntCAProfile: %v", err)}
+	client.DirectoryURL = prof.DirUrl
+	client.HTTPClient, err = CAHttpClient(prof)
+	if err != nil {return nil, fmt.Errorf("CAHttpClient: %v", err)}
+	// the account url saves a lookup of the account
+	client.KID = acme.KeyID(leAcnt.AcntId)
+
+	if dbg {fmt.Printf("Acme Url [profile: %s]: %s\n", prof.Name, client.DirectoryURL)}
+
+	// the account key of a key store other than file does not live in the LEAcnt directory
+	if !IsFileKeyStore(leAcnt.KeyStore) {
+		ks, err := NewKeyStore(leAcnt.KeyStore)
+		if err != nil {return nil, fmt.Errorf("NewKeyStore: %v", err)}
+		client.Key, err = ks.LoadKey(AcntKeyId(&leAcnt))
+		if err != nil {return nil, fmt.Errorf("key store %s: LoadKey %s: %v", ks.Name(), AcntKeyId(&leAcnt), err)}
+		return &client, nil
+	}
 
 	if len(leAcnt.PrivKeyFilnam) == 0 {
 		return nil, fmt.Errorf("no private Key file name found!\n")
@@ -449,24 +578,16 @@ func GetLEClient(acntNam string, dbg bool) (cl *acme.Client, err error) {
 		return nil, fmt.Errorf("no public key file: %v", err)
 	}
 
-	if leAcnt.UseProd {
-    	client.DirectoryURL = leAcnt.ProdUrl
-	} else {
-    	client.DirectoryURL = leAcnt.TestUrl
-	}
-
-	if dbg {fmt.Printf("Acme Url [prod: %t]: %s\n", leAcnt.UseProd, client.DirectoryURL)}
-
     pemEncoded, err := os.ReadFile(privFilnam)
     if err != nil {return nil, fmt.Errorf("os.Read Priv Key: %v", err)}
 
     pemEncodedPub, err := os.ReadFile(pubFilnam)
     if err != nil {return nil, fmt.Errorf("os.Read Pub Key: %v", err)}
 
-    block, _ := pem.Decode([]byte(pemEncoded))
-    x509Encoded := block.Bytes
-    privateKey, err := x509.ParseECPrivateKey(x509Encoded)
-    if err != nil {return nil, fmt.Errorf("x509.ParseECPivateKey: %v", err)}
+    privKey, err := decodeKeyPem(pemEncoded, privFilnam)
+    if err != nil {return nil, fmt.Errorf("decodeKeyPem: %v", err)}
+    privateKey, ok := privKey.(*ecdsa.PrivateKey)
+    if !ok {return nil, fmt.Errorf("account key %s is not an ecdsa key!", privFilnam)}
 
     blockPub, _ := pem.Decode([]byte(pemEncodedPub))
     x509EncodedPub := blockPub.Bytes
@@ -499,8 +620,38 @@ func RegisterClient(ctx context.Context, client *acme.Client, contacts []string,
 }
 
 // generate cert names
+// function that returns true for a wildcard domain such as *.example.com
+func IsWildcard(domain string) (ok bool) {
+	return strings.HasPrefix(domain, "*.")
+}
+
+// function that returns the domain without the wildcard label
+func BaseDomain(domain string) (base string) {
+	return strings.TrimPrefix(domain, "*.")
+}
+
+// function that finds the csr domain of an authorization
+// the authorization of *.example.com has the identifier example.com with Wildcard set
+func FindAuthDomain(csrList *CsrList, auth *acme.Authorization) (idx int, err error) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		domain := csrList.Domains[i].Domain
+		if BaseDomain(domain) != auth.Identifier.Value {continue}
+		if IsWildcard(domain) != auth.Wildcard {continue}
+		return i, nil
+	}
+	return -1, fmt.Errorf("no csr domain for authorization %s wildcard: %t!", auth.Identifier.Value, auth.Wildcard)
+}
+
+// wildcard domains are named wildcard_<base name>
 func GenerateCertName(domain string)(certName string, err error) {
 
+	if IsWildcard(domain) {
+		baseNam, err := GenerateCertName(BaseDomain(domain))
+		if err != nil {return "", err}
+		return "wildcard_" + baseNam, nil
+	}
+
 	domByt := []byte(domain)
 	suc := false
 	for i:=len(domByt)-1; i> 0; i-- {
@@ -517,16 +668,32 @@ func GenerateCertName(domain string)(certName string, err error) {
 }
 
 // from https://github.com/eggsampler/acme/blob/master/examples/certbot/certbot.go#L269
-func SaveKeyPem(certKey *ecdsa.PrivateKey, keyFilNam string) (err error) {
-	certKeyEnc, err := x509.MarshalECPrivateKey(certKey)
-	if err != nil {
-		log.Fatalf("Error encoding key: %v", err)
+// ecdsa keys are saved as sec1, rsa keys as pkcs1 and ed25519 keys as pkcs8
+func SaveKeyPem(certKey crypto.Signer, keyFilNam string) (err error) {
+
+	var pemType string
+	var certKeyEnc []byte
+	switch key := certKey.(type) {
+	case *ecdsa.PrivateKey:
+		pemType = "EC PRIVATE KEY"
+		certKeyEnc, err = x509.MarshalECPrivateKey(key)
+	case *rsa.PrivateKey:
+		pemType = "RSA PRIVATE KEY"
+		certKeyEnc = x509.MarshalPKCS1PrivateKey(key)
+	case ed25519.PrivateKey:
+		pemType = "PRIVATE KEY"
+		certKeyEnc, err = x509.MarshalPKCS8PrivateKey(key)
+	default:
+		return fmt.Errorf("unsupported key type %T!", certKey)
 	}
+	if err != nil {return fmt.Errorf("Error encoding key: %v", err)}
 
-	b := pem.EncodeToMemory(&pem.Block{
-		Type:  "EC PRIVATE KEY",
+	// the key is encrypted if keyEnc is set
+	b, err := encodeKeyPem(certKey, &pem.Block{
+		Type:  pemType,
 		Bytes: certKeyEnc,
 	})
+	if err != nil {return fmt.Errorf("encodeKeyPem: %v", err)}
 
 	if err = os.WriteFile(keyFilNam, b, 0600); err != nil {
         return fmt.Errorf("Error writing key file %q: %v", keyFilNam, err)
@@ -631,9 +798,11 @@ func CreateCsrTpl(csrData CsrDat) (template x509.CertificateRequest) {
 	rawSubj := subj.ToRDNSequence()
 
 	asn1Subj, _ := asn1.Marshal(rawSubj)
+	// an unknown key type leaves the algorithm to x509.CreateCertificateRequest
+	sigAlgo, _ := SigAlgo(GetKeyType(csrData))
 	template = x509.CertificateRequest{
 		RawSubject:         asn1Subj,
-		SignatureAlgorithm: x509.ECDSAWithSHA256,
+		SignatureAlgorithm: sigAlgo,
 		DNSNames: []string{csrData.Domain},
 	}
 	return template
@@ -666,9 +835,17 @@ fmt.Printf("dbg -- nam[%d;%d]:\n%s\n", domIdx,namIdx, nam.CommonName)
 
 	asn1Subj, _ := asn1.Marshal(rawSubj)
 
+	keyType := GetKeyType((*csrList).Domains[namIdx])
+	if domIdx < 0 {
+		keyType, err = CsrKeyType(csrList)
+		if err != nil {return template, fmt.Errorf("CsrKeyType: %v", err)}
+	}
+	sigAlgo, err := SigAlgo(keyType)
+	if err != nil {return template, fmt.Errorf("SigAlgo: %v", err)}
+
 	template = x509.CertificateRequest{
 		RawSubject:         asn1Subj,
-		SignatureAlgorithm: x509.ECDSAWithSHA256,
+		SignatureAlgorithm: sigAlgo,
 	}
 
 	dnsNam :=[]string{}
@@ -686,7 +863,7 @@ fmt.Printf("dbg -- nam[%d;%d]:\n%s\n", domIdx,namIdx, nam.CommonName)
 	return template, nil
 }
 
-func CreateCsr(csrTpl x509.CertificateRequest, certKey *ecdsa.PrivateKey)(csr []byte,err error) {
+func CreateCsr(csrTpl x509.CertificateRequest, certKey crypto.Signer)(csr []byte,err error) {
 
     csr, err = x509.CreateCertificateRequest(rand.Reader, &csrTpl, certKey)
     if err != nil { return csr, fmt.Errorf("CreateCertReq: %v",err)}
@@ -763,8 +940,9 @@ func SaveAcmeClient(client *acme.Client, filNam string) (err error) {
     x509Encoded, err := x509.MarshalECPrivateKey(privateKey)
     if err != nil {return fmt.Errorf("x509.MarshalECPrivateKey: %v", err)}
 
-    pemEncoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
-    err = os.WriteFile(privKeyFilNam, pemEncoded, 0644)
+    pemEncoded, err := encodeKeyPem(privateKey, &pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
+    if err != nil {return fmt.Errorf("encodeKeyPem: %v", err)}
+    err = os.WriteFile(privKeyFilNam, pemEncoded, 0600)
     if err != nil {return fmt.Errorf("pem priv key write file: %v", err)}
 
     x509EncodedPub, err := x509.MarshalPKIXPublicKey(publicKey)
@@ -810,10 +988,10 @@ func GetAcmeClient(acntNam string) (cl *acme.Client, err error) {
     pemEncodedPub, err := os.ReadFile(pubFilNam)
     if err != nil {return nil, fmt.Errorf("os.Read Pub Key: %v", err)}
 
-    block, _ := pem.Decode([]byte(pemEncoded))
-    x509Encoded := block.Bytes
-    privateKey, err := x509.ParseECPrivateKey(x509Encoded)
-    if err != nil {return nil, fmt.Errorf("x509.ParseECPivateKey: %v", err)}
+    privKey, err := decodeKeyPem(pemEncoded, privFilNam)
+    if err != nil {return nil, fmt.Errorf("decodeKeyPem: %v", err)}
+    privateKey, ok := privKey.(*ecdsa.PrivateKey)
+    if !ok {return nil, fmt.Errorf("account key %s is not an ecdsa key!", privFilNam)}
 
     blockPub, _ := pem.Decode([]byte(pemEncodedPub))
     x509EncodedPub := blockPub.Bytes
@@ -827,30 +1005,16 @@ func GetAcmeClient(acntNam string) (cl *acme.Client, err error) {
     return &client, nil
 }
 
+// function that removes the challenge data of the order from the state of the csr list
+// the csr file itself is not written
 func CleanCsrFil (csrFilnam string, csrList *CsrList) (err error) {
 
-    log.Printf("cleaning csr file\n")
-
-    numAcmeDom := len(csrList.Domains)
-
-    for i:= 0; i< numAcmeDom; i++ {
-        domain := csrList.Domains[i]
-        domain.ChalRecId = ""
-        domain.Token = ""
-        domain.TokVal = ""
-        domain.TokUrl = ""
-        domain.TokIssue = time.Time{}
-        domain.TokExp = time.Time{}
-		domain.OrderUrl = ""
-        csrList.Domains[i] = domain
-    }
+    log.Printf("cleaning csr state\n")
 
+	ResetCsrOrder(csrList)
     csrList.LastLU = time.Now()
-	csrList.OrderUrl = ""
-    err = WriteCsrFil(csrFilnam, csrList)
-    if err != nil { return fmt.Errorf("certLib.WriteCsrFil: %v\n", err)}
-
-//    log.Printf("success writing Csr File\n")
+    err = SaveCsrState(csrFilnam, csrList)
+    if err != nil { return fmt.Errorf("SaveCsrState: %v\n", err)}
 
     return nil
 }
@@ -867,6 +1031,9 @@ func PrintCsrList(csrlist *CsrList) {
 	}
 	fmt.Printf("orderUrl: %s\n", csrlist.OrderUrl)
 	fmt.Printf("certUrl:  %s\n", csrlist.CertUrl)
+	fmt.Printf("dns prov: %s\n", csrlist.DnsProvider)
+	fmt.Printf("http01:   addr: %s webroot: %s\n", csrlist.Http01.Addr, csrlist.Http01.Webroot)
+	fmt.Printf("tlsAlpn01: addr: %s\n", csrlist.TlsAlpn01.Addr)
     numDom := len(csrlist.Domains)
     fmt.Printf("domains:  %d\n", numDom)
     for i:=0; i< numDom; i++ {
@@ -876,6 +1043,7 @@ func PrintCsrList(csrlist *CsrList) {
 		fmt.Printf("===========\n")
 		fmt.Printf("    name:      %s\n", csrdat.Domain)
         fmt.Printf("    email:     %s\n", csrdat.Email)
+		fmt.Printf("    chal type: %s\n", GetChalType(csrdat))
 		fmt.Printf("    chal rec:  %s\n", csrdat.ChalRecId)
      	fmt.Printf("    token:     %s\n", csrdat.Token)
 		fmt.Printf("    tokval:    %s\n", csrdat.TokVal)
@@ -917,6 +1085,11 @@ func PrintLEAcnt(acnt *LEObj) {
 	fmt.Printf("Prod Url:   %s\n", acnt.ProdUrl)
 	fmt.Printf("remove:     %t\n", acnt.Remove)
 	fmt.Printf("useProd:    %t\n", acnt.UseProd)
+	if len(acnt.Profile) > 0 {fmt.Printf("CA profile: %s\n", acnt.Profile)}
+	fmt.Printf("dns prov:   %s\n", acnt.DnsProvider)
+	if len(acnt.Status) > 0 {fmt.Printf("status:     %s\n", acnt.Status)}
+	if len(acnt.EabKid) > 0 {fmt.Printf("eab kid:    %s\n", acnt.EabKid)}
+	if !acnt.Deactivated.IsZero() {fmt.Printf("deactivated: %s\n", acnt.Deactivated.Format(time.RFC1123))}
 	fmt.Printf("contacts:   %d\n", len(acnt.Contacts))
 	for i:=0; i< len(acnt.Contacts); i++ {
 		fmt.Printf("contact[%d]: %s\n", i+1, acnt.Contacts[i])
@@ -951,6 +1124,9 @@ func PrintJsAccount (acnt *JsAcnt) {
     }
     fmt.Printf("OrdersURL:   %s\n", acnt.OrdersURL)
     fmt.Printf("AgreedTerms: %s\n", acnt.AgreedTerms)
+    if acnt.ExternalAccountBinding != nil {
+        fmt.Printf("ExtAcct KID: %s\n", acnt.ExternalAccountBinding.KID)
+    }
 }
 
 func PrintClient (client *acme.Client) {
@@ -1377,6 +1553,12 @@ func PrintCertObj(cert *certLibObj) {
 	fmt.Printf("CF Dir:      %s\n", cert.CfDir)
 	fmt.Printf("Csr Dir:    %s\n", cert.CsrDir)
 	fmt.Printf("Cf Api File: %s\n", cert.CfApiFilnam)
+	fmt.Printf("Rfc2136 File: %s\n", cert.Rfc2136Filnam)
+	fmt.Printf("Responder File: %s\n", cert.ResponderFilnam)
+	fmt.Printf("Chal Tok File: %s\n", cert.ChalTokFilnam)
+	fmt.Printf("Renew File:  %s\n", cert.RenewFilnam)
+	fmt.Printf("Renew Sched: %s\n", cert.RenewSchedFilnam)
+	fmt.Printf("Inventory:   %s\n", cert.InventoryFilnam)
 	fmt.Printf("************** end certLibObj ***************\n")
 }
 
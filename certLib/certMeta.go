@@ -0,0 +1,157 @@
+// certMeta.go
+// meta data file that is saved next to each certificate
+// the file records how the certificate was obtained, so that it can be renewed
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+)
+
+// content of the file <certNam>.meta.yaml in the cert directory
+type CertMeta struct {
+	CertNam string `yaml:"certName"`
+	// csr file (relative to the csr directory) that produced the certificate
+	CsrFil string `yaml:"csrFile"`
+	// program that issued the certificate and renews it, e.g. createMultiCerts; empty: the renewal program of the renewDaemon
+	Cmd string `yaml:"cmd"`
+	Account string `yaml:"account"`
+	// key id of the certificate key: the key file, or the label of the key in a key store other than file
+	KeyId string `yaml:"keyId"`
+	CAProfile string `yaml:"caProfile"`
+	CAUrl string `yaml:"caUrl"`
+	Domains []string `yaml:"domains"`
+	OrderUrl string `yaml:"orderUrl"`
+	CertUrl string `yaml:"certUrl"`
+	Issued time.Time `yaml:"issued"`
+	NotBefore time.Time `yaml:"notBefore"`
+	NotAfter time.Time `yaml:"notAfter"`
+	// renewal window suggested by the CA (ARI)
+	AriId string `yaml:"ariId"`
+	AriStart time.Time `yaml:"ariStart"`
+	AriEnd time.Time `yaml:"ariEnd"`
+	AriExplain string `yaml:"ariExplain"`
+	// time of the next renewalInfo request
+	AriNext time.Time `yaml:"ariNext"`
+	// a revoked certificate is not renewed
+	Revoked time.Time `yaml:"revoked"`
+	RevokeReason string `yaml:"revokeReason"`
+}
+
+// function that returns the name of the meta file of a certificate
+func CertMetaFilnam(certDir string, certNam string) (filnam string) {
+	return certDir + "/" + certNam + ".meta.yaml"
+}
+
+func ReadCertMeta(metaFilnam string) (meta *CertMeta, err error) {
+
+	bytData, err := os.ReadFile(metaFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	meta = &CertMeta{}
+	err = yaml.Unmarshal(bytData, meta)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+	return meta, nil
+}
+
+func WriteCertMeta(metaFilnam string, meta *CertMeta) (err error) {
+
+	metaByt, err := yaml.Marshal(meta)
+	if err != nil {return fmt.Errorf("yaml Marshal: %v", err)}
+
+	err = writeFileAtomic(metaFilnam, metaByt, 0600)
+	if err != nil {return fmt.Errorf("writeFileAtomic: %v", err)}
+	return nil
+}
+
+// function that creates the meta data of a certificate from the csr list and the certificate chain
+func NewCertMeta(certNam string, csrFil string, csrList *CsrList, derCerts [][]byte) (meta *CertMeta, err error) {
+
+	if len(derCerts) == 0 {return nil, fmt.Errorf("no certificates!")}
+	leaf, err := x509.ParseCertificate(derCerts[0])
+	if err != nil {return nil, fmt.Errorf("x509.ParseCertificate: %v", err)}
+
+	meta = &CertMeta{
+		CertNam: certNam,
+		CsrFil: csrFil,
+		Account: csrList.AcntName,
+		OrderUrl: csrList.OrderUrl,
+		CertUrl: csrList.CertUrl,
+		Issued: time.Now(),
+		NotBefore: leaf.NotBefore,
+		NotAfter: leaf.NotAfter,
+	}
+	for i:=0; i< len(csrList.Domains); i++ {
+		meta.Domains = append(meta.Domains, csrList.Domains[i].Domain)
+	}
+	return meta, nil
+}
+
+// function that reads the leaf certificate of a pem encoded certificate chain
+func ReadLeafCert(certFilnam string) (cert *x509.Certificate, err error) {
+
+	pemData, err := os.ReadFile(certFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	for {
+		block, rest := pem.Decode(pemData)
+		if block == nil {break}
+		if block.Type == "CERTIFICATE" {
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err != nil {return nil, fmt.Errorf("x509.ParseCertificate: %v", err)}
+			return cert, nil
+		}
+		pemData = rest
+	}
+	return nil, fmt.Errorf("no certificate in %s!", certFilnam)
+}
+
+// function that returns the names of all certificates (*.crt) in the cert directory
+func ListCertNames(certDir string) (certNames []string, err error) {
+
+	entries, err := os.ReadDir(certDir)
+	if err != nil {return nil, fmt.Errorf("os.ReadDir: %v", err)}
+
+	for _, entry := range entries {
+		if entry.IsDir() {continue}
+		nam := entry.Name()
+		if !strings.HasSuffix(nam, ".crt") {continue}
+		certNames = append(certNames, strings.TrimSuffix(nam, ".crt"))
+	}
+	return certNames, nil
+}
+
+func PrintCertMeta(meta *CertMeta) {
+
+	fmt.Printf("*************** Cert Meta: %s ***************\n", meta.CertNam)
+	fmt.Printf("csr file:   %s\n", meta.CsrFil)
+	fmt.Printf("account:    %s\n", meta.Account)
+	if len(meta.CAProfile) > 0 {fmt.Printf("CA profile: %s\n", meta.CAProfile)}
+	if len(meta.CAUrl) > 0 {fmt.Printf("CA url:     %s\n", meta.CAUrl)}
+	fmt.Printf("domains:    %v\n", meta.Domains)
+	fmt.Printf("order url:  %s\n", meta.OrderUrl)
+	fmt.Printf("cert url:   %s\n", meta.CertUrl)
+	fmt.Printf("issued:     %s\n", meta.Issued.Format(time.RFC1123))
+	fmt.Printf("not before: %s\n", meta.NotBefore.Format(time.RFC1123))
+	fmt.Printf("not after:  %s\n", meta.NotAfter.Format(time.RFC1123))
+	if len(meta.AriId) > 0 {
+		fmt.Printf("ari id:     %s\n", meta.AriId)
+		fmt.Printf("ari window: %s - %s\n", meta.AriStart.Format(time.RFC1123), meta.AriEnd.Format(time.RFC1123))
+		if len(meta.AriExplain) > 0 {fmt.Printf("ari explain: %s\n", meta.AriExplain)}
+	}
+	if !meta.Revoked.IsZero() {
+		fmt.Printf("revoked:    %s reason: %s\n", meta.Revoked.Format(time.RFC1123), meta.RevokeReason)
+	}
+	fmt.Printf("************* End Cert Meta *****************\n")
+}
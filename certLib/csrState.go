@@ -0,0 +1,142 @@
+// csrState.go
+// runtime state of the csr lists
+// the challenges, the order and the issue state of a csr list are saved in the state file LEAcnt/csrState/<csr list>.yaml
+// the programs only read the csr files, so that the csr files can be read-only and kept in git
+// csr files of earlier versions hold the runtime fields themselves; these fields are read as the state of the csr list
+// until the first state file of the csr list is written
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+)
+
+// runtime state of a csr list
+type CsrRun struct {
+	LastLU time.Time `yaml:"last"`
+	OrderUrl string `yaml:"orderUrl"`
+	CertUrl string `yaml:"certUrl"`
+	// state of the issuance of createCertsV3
+	Issue IssueState `yaml:"issue"`
+}
+
+// runtime state of a domain of a csr list
+type DomRun struct {
+	ChalRecId string `yaml:"chalrec"`
+	Token	string `yaml:"token"`
+	TokVal string `yaml:"tokval"`
+	TokUrl string `yaml:"tokUrl"`
+	TokIssue time.Time `yaml:"issue"`
+	TokExp time.Time `yaml:"expire"`
+	OrderUrl string `yaml:"orderUrl"`
+	CertUrl string `yaml:"certUrl"`
+}
+
+// content of the state file of a csr list
+// the yaml fields are the runtime fields of the csr files of earlier versions
+type CsrState struct {
+	CsrFil string `yaml:"csrFile"`
+	CsrRun `yaml:",inline"`
+	Domains []DomState `yaml:"domains"`
+}
+
+type DomState struct {
+	Domain string `yaml:"domain"`
+	DomRun `yaml:",inline"`
+}
+
+// function that returns the name of a csr list: the file name of the csr file without .yaml
+func CsrNam(csrFilnam string) (csrNam string) {
+	return strings.TrimSuffix(filepath.Base(csrFilnam), ".yaml")
+}
+
+// function that returns the name of the state file of a csr file
+func CsrStateFilnam(csrFilnam string) (stateFilnam string, err error) {
+
+    leAcnt := os.Getenv("LEAcnt")
+    if len(leAcnt) < 1 {return "", fmt.Errorf("could not resolve env var LEAcnt!")}
+	return leAcnt + "/csrState/" + CsrNam(csrFilnam) + ".yaml", nil
+}
+
+// function that sets the runtime fields of the csr list from its state file
+// csrData is the content of the csr file; without state file its runtime fields are the state (migration)
+func loadCsrState(csrFilnam string, csrData []byte, csrList *CsrList) (err error) {
+
+	stateFilnam, err := CsrStateFilnam(csrFilnam)
+	if err != nil {return err}
+
+	migrate := false
+	stateData, err := os.ReadFile(stateFilnam)
+	if err != nil {
+		if !os.IsNotExist(err) {return fmt.Errorf("os.ReadFile: %v", err)}
+		stateData = csrData
+		migrate = true
+	}
+
+	state := &CsrState{}
+	err = yaml.Unmarshal(stateData, state)
+	if err != nil {return fmt.Errorf("yaml Unmarshal state: %v", err)}
+
+	if migrate && hasCsrState(state) {
+		log.Printf("csr file %s has runtime fields; they are moved to %s with the next save\n", csrFilnam, stateFilnam)
+	}
+
+	csrList.CsrRun = state.CsrRun
+	// the state of a domain belongs to the domain name; domains added to the csr file have no state
+	for i:=0; i< len(csrList.Domains); i++ {
+		for _, domState := range state.Domains {
+			if domState.Domain == csrList.Domains[i].Domain {
+				csrList.Domains[i].DomRun = domState.DomRun
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// function that tests whether a state has runtime data
+func hasCsrState(state *CsrState) (ok bool) {
+
+	if len(state.OrderUrl) > 0 || len(state.CertUrl) > 0 || state.Issue.State != IssueNew {return true}
+	for _, domState := range state.Domains {
+		if domState.DomRun != (DomRun{}) {return true}
+	}
+	return false
+}
+
+// function that saves the runtime fields of the csr list in its state file
+// the csr file is not written
+func SaveCsrState(csrFilnam string, csrList *CsrList) (err error) {
+
+	stateFilnam, err := CsrStateFilnam(csrFilnam)
+	if err != nil {return err}
+
+	state := CsrState{
+		CsrFil: csrFilnam,
+		CsrRun: csrList.CsrRun,
+	}
+	for _, dom := range csrList.Domains {
+		state.Domains = append(state.Domains, DomState{Domain: dom.Domain, DomRun: dom.DomRun})
+	}
+
+	stateByt, err := yaml.Marshal(state)
+	if err != nil {return fmt.Errorf("yaml Marshal: %v", err)}
+
+	err = os.MkdirAll(filepath.Dir(stateFilnam), 0755)
+	if err != nil {return fmt.Errorf("os.MkdirAll: %v", err)}
+
+	err = writeFileAtomic(stateFilnam, stateByt, 0644)
+	if err != nil {return fmt.Errorf("writeFileAtomic: %v", err)}
+	return nil
+}
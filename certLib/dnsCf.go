@@ -0,0 +1,199 @@
+// dnsCf.go
+// cloudflare implementation of the DNSProvider interface
+// challenge records at the apex of a zone of the zone file are changed with cfLib
+// records inside a zone and zones found with the SOA lookup use the cloudflare api with the api token (see cfRecApi)
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	cfLib "acme/acmeDns/cfLib"
+	"github.com/cloudflare/cloudflare-go"
+	yaml "github.com/goccy/go-yaml"
+)
+
+// methods of the cfLib api object used by the provider
+type cfApiObj interface {
+	AddDnsChalRecord(zoneId string, tokVal string) (recId string, err error)
+	DelDnsChalRecord(acmeZone cfLib.ZoneAcme) (err error)
+	ListDnsRecords(zoneId string) (dnsRecs *[]cloudflare.DNSRecord, err error)
+}
+
+// api token of the cloudflare api in the file cfDns.yaml; the environment variable cfApiToken takes precedence
+type cfTokenCfg struct {
+	ApiToken string `yaml:"apiToken"`
+}
+
+// ttl of the challenge records created with the cloudflare api
+const cfChalTTL = 60
+
+type cfProvider struct {
+	api cfApiObj
+	zoneFilnam string
+	apiFilnam string
+	// cloudflare api for the records that cfLib cannot create; created on first use
+	recApi *cloudflare.API
+	// zone ids of the zones that are not in the zone file
+	zoneIds map[string]string
+}
+
+func init() {
+	RegisterDnsProvider("cloudflare", newCfProvider)
+}
+
+func newCfProvider(certObj *certLibObj) (prov DNSProvider, err error) {
+
+	if certObj == nil {return nil, fmt.Errorf("no certLibObj!")}
+
+	api, err := cfLib.InitCfApi(certObj.CfApiFilnam)
+	if err != nil {return nil, fmt.Errorf("cfLib.InitCfApi: %v", err)}
+
+	cfProv := cfProvider{
+		api: api,
+		zoneFilnam: certObj.ZoneFilnam,
+		apiFilnam: certObj.CfApiFilnam,
+		zoneIds: map[string]string{},
+	}
+	return &cfProv, nil
+}
+
+func (cf *cfProvider) Name() string {
+	return "cloudflare"
+}
+
+// function that reads the cloudflare zones from the zone file
+func (cf *cfProvider) Zones() (zones []DnsZone, err error) {
+
+	zoneList, err := cfLib.ReadZoneShortFile(cf.zoneFilnam)
+	if err != nil {return nil, fmt.Errorf("cfLib.ReadZoneShortFile: %v", err)}
+
+	zones = make([]DnsZone, len(zoneList.Zones))
+	for i:=0; i< len(zoneList.Zones); i++ {
+		zones[i].Name = zoneList.Zones[i].Name
+		zones[i].Id = zoneList.Zones[i].Id
+	}
+	return zones, nil
+}
+
+// function that returns the cloudflare api used for records inside a zone
+func (cf *cfProvider) cfRecApi() (api *cloudflare.API, err error) {
+
+	if cf.recApi != nil {return cf.recApi, nil}
+
+	token := os.Getenv("cfApiToken")
+	if len(token) == 0 {
+		bytData, err := os.ReadFile(cf.apiFilnam)
+		if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+		tokCfg := cfTokenCfg{}
+		err = yaml.Unmarshal(bytData, &tokCfg)
+		if err != nil {return nil, fmt.Errorf("yaml Unmarshal %s: %v", cf.apiFilnam, err)}
+		token = tokCfg.ApiToken
+	}
+	if len(token) == 0 {return nil, fmt.Errorf("no api token: set apiToken in %s or cfApiToken!", cf.apiFilnam)}
+
+	api, err = cloudflare.NewWithAPIToken(token)
+	if err != nil {return nil, fmt.Errorf("cloudflare.NewWithAPIToken: %v", err)}
+	cf.recApi = api
+	return api, nil
+}
+
+// function that returns the cloudflare id of a zone
+// zones found with the SOA lookup have no id; their id is looked up by name
+func (cf *cfProvider) zoneId(zone DnsZone) (zoneId string, err error) {
+
+	if len(zone.Id) > 0 {return zone.Id, nil}
+	if zoneId, ok := cf.zoneIds[zone.Name]; ok {return zoneId, nil}
+
+	api, err := cf.cfRecApi()
+	if err != nil {return "", err}
+	zoneId, err = api.ZoneIDByName(zone.Name)
+	if err != nil {return "", fmt.Errorf("cloudflare: zone %s: %v", zone.Name, err)}
+	cf.zoneIds[zone.Name] = zoneId
+	return zoneId, nil
+}
+
+// function that tests whether cfLib handles the record: cfLib only creates challenge records at the apex of a zone of the zone file
+func (cf *cfProvider) useCfLib(rec *ChalRec) (ok bool) {
+	return len(rec.Zone.Id) > 0 && rec.Name == ChalRecName(rec.Zone.Name)
+}
+
+func (cf *cfProvider) Present(rec *ChalRec) (err error) {
+
+	if cf.useCfLib(rec) {
+		recId, err := cf.api.AddDnsChalRecord(rec.Zone.Id, rec.Value)
+		if err != nil {return fmt.Errorf("AddDnsChalRecord: %v", err)}
+		rec.RecId = recId
+		return nil
+	}
+
+	zoneId, err := cf.zoneId(rec.Zone)
+	if err != nil {return err}
+	api, err := cf.cfRecApi()
+	if err != nil {return err}
+
+	// the record name is the full name; the api places it in the zone
+	dnsRec := cloudflare.DNSRecord{
+		Type: "TXT",
+		Name: strings.TrimSuffix(rec.Name, "."),
+		Content: rec.Value,
+		TTL: cfChalTTL,
+	}
+	resp, err := api.CreateDNSRecord(context.Background(), zoneId, dnsRec)
+	if err != nil {return fmt.Errorf("cloudflare: CreateDNSRecord %s: %v", rec.Name, err)}
+	rec.RecId = resp.Result.ID
+	return nil
+}
+
+func (cf *cfProvider) CleanUp(rec *ChalRec) (err error) {
+
+	if len(rec.RecId) == 0 {return fmt.Errorf("cloudflare: no record id for %s!", rec.Name)}
+
+	if cf.useCfLib(rec) {
+		acmeZone := cfLib.ZoneAcme{
+			Name: rec.Zone.Name,
+			Id: rec.Zone.Id,
+			AcmeId: rec.RecId,
+		}
+		err = cf.api.DelDnsChalRecord(acmeZone)
+		if err != nil {return fmt.Errorf("DelDnsChalRecord: %v", err)}
+		return nil
+	}
+
+	zoneId, err := cf.zoneId(rec.Zone)
+	if err != nil {return err}
+	api, err := cf.cfRecApi()
+	if err != nil {return err}
+	err = api.DeleteDNSRecord(context.Background(), zoneId, rec.RecId)
+	if err != nil {return fmt.Errorf("cloudflare: DeleteDNSRecord %s: %v", rec.Name, err)}
+	return nil
+}
+
+func (cf *cfProvider) ListChalRecs(zone DnsZone) (recs []ChalRec, err error) {
+
+	zoneId, err := cf.zoneId(zone)
+	if err != nil {return nil, err}
+	dnsRecs, err := cf.api.ListDnsRecords(zoneId)
+	if err != nil {return nil, fmt.Errorf("ListDnsRecords: %v", err)}
+
+	for _, dnsRec := range *dnsRecs {
+		if dnsRec.Type != "TXT" {continue}
+		if strings.Index(dnsRec.Name, "_acme-challenge.") != 0 {continue}
+		rec := ChalRec{
+			Domain: strings.TrimPrefix(dnsRec.Name, "_acme-challenge."),
+			Zone: zone,
+			Name: dnsRec.Name,
+			Value: dnsRec.Content,
+			RecId: dnsRec.ID,
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
@@ -0,0 +1,105 @@
+// dnsMem.go
+// in-memory implementation of the DNSProvider interface
+// the provider does not change any dns server and is meant for tests
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"sync"
+)
+
+type MemDnsProvider struct {
+	mu sync.Mutex
+	zones []DnsZone
+	recs map[string]ChalRec
+	nextId int
+}
+
+func init() {
+	RegisterDnsProvider("memory", func(certObj *certLibObj) (DNSProvider, error) {
+		return NewMemDnsProvider(), nil
+	})
+}
+
+// function that creates an in-memory provider that serves the zones listed
+func NewMemDnsProvider(zoneNames ...string) (prov *MemDnsProvider) {
+
+	prov = &MemDnsProvider{
+		recs: make(map[string]ChalRec),
+	}
+	for _, nam := range zoneNames {
+		prov.AddZone(nam)
+	}
+	return prov
+}
+
+// function that adds a zone to the provider
+func (mem *MemDnsProvider) AddZone(zoneNam string) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	zone := DnsZone{
+		Name: zoneNam,
+		Id: fmt.Sprintf("mem-zone-%d", len(mem.zones)+1),
+	}
+	mem.zones = append(mem.zones, zone)
+}
+
+func (mem *MemDnsProvider) Name() string {
+	return "memory"
+}
+
+func (mem *MemDnsProvider) Zones() (zones []DnsZone, err error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	zones = make([]DnsZone, len(mem.zones))
+	copy(zones, mem.zones)
+	return zones, nil
+}
+
+func (mem *MemDnsProvider) Present(rec *ChalRec) (err error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	if len(rec.Name) == 0 {return fmt.Errorf("memory: no record name!")}
+	mem.nextId++
+	rec.RecId = fmt.Sprintf("mem-rec-%d", mem.nextId)
+	mem.recs[rec.RecId] = *rec
+	return nil
+}
+
+func (mem *MemDnsProvider) CleanUp(rec *ChalRec) (err error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	if _, ok := mem.recs[rec.RecId]; !ok {return fmt.Errorf("memory: no record with id: %s!", rec.RecId)}
+	delete(mem.recs, rec.RecId)
+	return nil
+}
+
+func (mem *MemDnsProvider) ListChalRecs(zone DnsZone) (recs []ChalRec, err error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	for _, rec := range mem.recs {
+		if rec.Zone.Name == zone.Name {recs = append(recs, rec)}
+	}
+	return recs, nil
+}
+
+// function that returns the values of all records with the name recNam
+func (mem *MemDnsProvider) LookupTxt(recNam string) (vals []string) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	for _, rec := range mem.recs {
+		if rec.Name == recNam {vals = append(vals, rec.Value)}
+	}
+	return vals
+}
@@ -0,0 +1,294 @@
+// dnsProp.go
+// checker that tests the propagation of the dns-01 challenge records
+// the checker queries every authoritative name server of the zone directly
+// a challenge record delegated with a CNAME or an NS record (e.g. to the dns responder) is followed to the servers of the target
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// propagation configuration of a csr list; durations are in seconds
+type PropCfg struct {
+	// resolver used to look up the name servers of the zone; default is SoaResolver or /etc/resolv.conf
+	Resolver string `yaml:"resolver"`
+	// name servers (host:port) queried instead of the NS set of the zone
+	NameServers []string `yaml:"nameServers"`
+	// port of the authoritative name servers; default 53
+	Port string `yaml:"port"`
+	// total time to wait for the records; default 120
+	Timeout int `yaml:"timeout"`
+	// first wait between attempts; doubled after each attempt up to MaxInterval; default 2
+	Interval int `yaml:"interval"`
+	// default 30
+	MaxInterval int `yaml:"maxInterval"`
+}
+
+type PropChecker struct {
+	Cfg PropCfg
+	Dbg bool
+	client *dns.Client
+}
+
+// function that creates a propagation checker
+func NewPropChecker(cfg PropCfg) (pc *PropChecker) {
+
+	if len(cfg.Port) == 0 {cfg.Port = "53"}
+	if cfg.Timeout == 0 {cfg.Timeout = 120}
+	if cfg.Interval == 0 {cfg.Interval = 2}
+	if cfg.MaxInterval == 0 {cfg.MaxInterval = 30}
+	if cfg.MaxInterval < cfg.Interval {cfg.MaxInterval = cfg.Interval}
+
+	pc = &PropChecker{
+		Cfg: cfg,
+		client: &dns.Client{Timeout: 5 * time.Second},
+	}
+	return pc
+}
+
+func (pc *PropChecker) resolver() (addr string, err error) {
+	if len(pc.Cfg.Resolver) > 0 {return pc.Cfg.Resolver, nil}
+	return soaResolverAddr()
+}
+
+// function that sends a query and returns the response
+func (pc *PropChecker) query(server string, nam string, qtype uint16, recurse bool) (resp *dns.Msg, err error) {
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(nam), qtype)
+	msg.RecursionDesired = recurse
+
+	resp, _, err = pc.client.Exchange(msg, server)
+	if err != nil {return nil, err}
+	// retry over tcp if the answer was truncated
+	if resp.Truncated {
+		tcpClient := *pc.client
+		tcpClient.Net = "tcp"
+		resp, _, err = tcpClient.Exchange(msg, server)
+		if err != nil {return nil, err}
+	}
+	return resp, nil
+}
+
+// function that returns the addresses (host:port) of the authoritative name servers of a zone
+func (pc *PropChecker) AuthServers(zoneNam string) (servers []string, err error) {
+
+	if len(pc.Cfg.NameServers) > 0 {return pc.Cfg.NameServers, nil}
+
+	resAddr, err := pc.resolver()
+	if err != nil {return nil, fmt.Errorf("resolver: %v", err)}
+
+	resp, err := pc.query(resAddr, zoneNam, dns.TypeNS, true)
+	if err != nil {return nil, fmt.Errorf("ns query %s: %v", zoneNam, err)}
+	if resp.Rcode != dns.RcodeSuccess {return nil, fmt.Errorf("ns query %s: %s!", zoneNam, dns.RcodeToString[resp.Rcode])}
+
+	var nsHosts []string
+	for _, rr := range resp.Answer {
+		ns, ok := rr.(*dns.NS)
+		if ok {nsHosts = append(nsHosts, ns.Ns)}
+	}
+	servers, err = pc.nsAddrs(resAddr, nsHosts, nil)
+	if err != nil {return nil, err}
+	if len(servers) == 0 {return nil, fmt.Errorf("no name servers found for zone %s!", zoneNam)}
+	return servers, nil
+}
+
+// function that returns the addresses (host:port) of name servers
+// the glue records of extra are used before the resolver resAddr is asked
+func (pc *PropChecker) nsAddrs(resAddr string, nsHosts []string, extra []dns.RR) (servers []string, err error) {
+
+	for _, host := range nsHosts {
+		glue := false
+		for _, rr := range extra {
+			if !strings.EqualFold(rr.Header().Name, host) {continue}
+			switch a := rr.(type) {
+			case *dns.A:
+				servers = append(servers, net.JoinHostPort(a.A.String(), pc.Cfg.Port))
+				glue = true
+			case *dns.AAAA:
+				servers = append(servers, net.JoinHostPort(a.AAAA.String(), pc.Cfg.Port))
+				glue = true
+			}
+		}
+		if glue {continue}
+
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			aresp, err := pc.query(resAddr, host, qtype, true)
+			if err != nil {return nil, fmt.Errorf("address query %s: %v", host, err)}
+			for _, arr := range aresp.Answer {
+				switch a := arr.(type) {
+				case *dns.A:
+					servers = append(servers, net.JoinHostPort(a.A.String(), pc.Cfg.Port))
+				case *dns.AAAA:
+					servers = append(servers, net.JoinHostPort(a.AAAA.String(), pc.Cfg.Port))
+				}
+			}
+		}
+	}
+	return servers, nil
+}
+
+// maximum number of CNAMEs and NS referrals followed from a challenge record
+const maxTxtDelegations = 8
+
+// function that returns the name servers and the name that hold the txt records of recNam
+// a CNAME restarts the lookup at the name servers of the zone of the target; an NS referral continues at the delegated name servers
+func (pc *PropChecker) TxtTarget(zoneNam string, recNam string) (servers []string, target string, err error) {
+
+	servers, err = pc.AuthServers(zoneNam)
+	if err != nil {return nil, "", fmt.Errorf("AuthServers: %v", err)}
+	target = dns.Fqdn(strings.ToLower(recNam))
+	// zone served by servers; its own NS records in the authority section are not a referral
+	curZone := dns.Fqdn(strings.ToLower(zoneNam))
+
+	for hop:=0; hop< maxTxtDelegations; hop++ {
+		var resp *dns.Msg
+		for _, srv := range servers {
+			resp, err = pc.query(srv, target, dns.TypeTXT, false)
+			if err == nil {break}
+		}
+		if err != nil {return nil, "", fmt.Errorf("txt query %s: %v", target, err)}
+
+		cname := ""
+		for _, rr := range resp.Answer {
+			if !strings.EqualFold(rr.Header().Name, target) {continue}
+			switch r := rr.(type) {
+			case *dns.TXT:
+				return servers, target, nil
+			case *dns.CNAME:
+				cname = r.Target
+			}
+		}
+
+		if len(cname) > 0 {
+			target = dns.Fqdn(strings.ToLower(cname))
+			resAddr, err := pc.resolver()
+			if err != nil {return nil, "", fmt.Errorf("resolver: %v", err)}
+			tgtZone, err := lookupZoneSOAAt(resAddr, target)
+			if err != nil {return nil, "", fmt.Errorf("zone of cname target %s: %v", target, err)}
+			servers, err = pc.AuthServers(tgtZone)
+			if err != nil {return nil, "", fmt.Errorf("AuthServers: %v", err)}
+			curZone = dns.Fqdn(tgtZone)
+			if pc.Dbg {log.Printf("prop: cname %s in zone %s\n", target, tgtZone)}
+			continue
+		}
+
+		// a referral has the NS records of the delegated zone in the authority section and no SOA
+		var nsHosts []string
+		delegZone := ""
+		for _, rr := range resp.Ns {
+			switch r := rr.(type) {
+			case *dns.SOA:
+				return servers, target, nil
+			case *dns.NS:
+				if strings.EqualFold(r.Hdr.Name, curZone) || !dns.IsSubDomain(r.Hdr.Name, target) {continue}
+				nsHosts = append(nsHosts, r.Ns)
+				delegZone = strings.ToLower(r.Hdr.Name)
+			}
+		}
+		if len(resp.Answer) > 0 || len(nsHosts) == 0 {return servers, target, nil}
+
+		resAddr, err := pc.resolver()
+		if err != nil {return nil, "", fmt.Errorf("resolver: %v", err)}
+		servers, err = pc.nsAddrs(resAddr, nsHosts, resp.Extra)
+		if err != nil {return nil, "", err}
+		if len(servers) == 0 {return nil, "", fmt.Errorf("no addresses of the name servers %v delegated for %s!", nsHosts, target)}
+		curZone = delegZone
+		if pc.Dbg {log.Printf("prop: %s delegated to %v\n", target, nsHosts)}
+	}
+	return nil, "", fmt.Errorf("%s: more than %d cnames and referrals!", recNam, maxTxtDelegations)
+}
+
+// function that queries one name server directly for the txt records of recNam
+// a missing record is not an error
+func (pc *PropChecker) QueryTxt(server string, recNam string) (vals []string, err error) {
+
+	resp, err := pc.query(server, recNam, dns.TypeTXT, false)
+	if err != nil {return nil, fmt.Errorf("txt query %s @%s: %v", recNam, server, err)}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+	default:
+		return nil, fmt.Errorf("txt query %s @%s: %s!", recNam, server, dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {continue}
+		vals = append(vals, strings.Join(txt.Txt, ""))
+	}
+	return vals, nil
+}
+
+// function that returns the txt values of recNam found on any authoritative name server of the zone or of the delegation target
+func (pc *PropChecker) LookupTxt(zoneNam string, recNam string) (vals []string, err error) {
+
+	servers, target, err := pc.TxtTarget(zoneNam, recNam)
+	if err != nil {return nil, fmt.Errorf("TxtTarget: %v", err)}
+
+	for _, srv := range servers {
+		srvVals, err := pc.QueryTxt(srv, target)
+		if err != nil {return nil, err}
+		for _, val := range srvVals {
+			if !ContainsTxt(vals, val) {vals = append(vals, val)}
+		}
+	}
+	return vals, nil
+}
+
+// function that tests once whether every authoritative name server of the zone or of the delegation target has all values of vals
+func (pc *PropChecker) CheckTxt(zoneNam string, recNam string, vals []string) (ok bool, err error) {
+
+	servers, target, err := pc.TxtTarget(zoneNam, recNam)
+	if err != nil {return false, fmt.Errorf("TxtTarget: %v", err)}
+
+	ok = true
+	for _, srv := range servers {
+		srvVals, err := pc.QueryTxt(srv, target)
+		if err != nil {return false, err}
+		for _, val := range vals {
+			if !ContainsTxt(srvVals, val) {
+				if pc.Dbg {log.Printf("prop: %s @%s: value %s missing\n", target, srv, val)}
+				ok = false
+			}
+		}
+	}
+	return ok, nil
+}
+
+// function that waits until every authoritative name server has all values of vals
+// the wait between attempts is doubled after each attempt up to MaxInterval
+func (pc *PropChecker) WaitTxt(zoneNam string, recNam string, vals []string) (err error) {
+
+	deadline := time.Now().Add(time.Duration(pc.Cfg.Timeout) * time.Second)
+	interval := time.Duration(pc.Cfg.Interval) * time.Second
+	maxInterval := time.Duration(pc.Cfg.MaxInterval) * time.Second
+
+	for attempt:=1; ; attempt++ {
+		ok, err := pc.CheckTxt(zoneNam, recNam, vals)
+		if ok {
+			if pc.Dbg {log.Printf("prop: %s propagated after %d attempts\n", recNam, attempt)}
+			return nil
+		}
+		if err != nil && pc.Dbg {log.Printf("prop: %s attempt[%d]: %v\n", recNam, attempt, err)}
+
+		if time.Now().Add(interval).After(deadline) {
+			if err != nil {return fmt.Errorf("%s not propagated after %d attempts: %v", recNam, attempt, err)}
+			return fmt.Errorf("%s not propagated after %d attempts!", recNam, attempt)
+		}
+		time.Sleep(interval)
+		interval *= 2
+		if interval > maxInterval {interval = maxInterval}
+	}
+}
@@ -0,0 +1,191 @@
+// dnsProp_test.go
+// tests of the propagation checker against fake authoritative servers on localhost
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// fake authoritative server of the zones zones
+// a record is only returned once its query count reaches the value of after
+type testAuthSrv struct {
+	mu sync.Mutex
+	zones []string
+	recs []dns.RR
+	// records of a delegation: the NS records and their glue
+	deleg []dns.RR
+	glue []dns.RR
+	after map[string]int
+	queries map[string]int
+}
+
+func newTestAuthSrv(zones ...string) (srv *testAuthSrv) {
+	return &testAuthSrv{zones: zones, after: map[string]int{}, queries: map[string]int{}}
+}
+
+func (srv *testAuthSrv) add(rrStr string) {
+	rr, err := dns.NewRR(rrStr)
+	if err != nil {panic(err)}
+	srv.recs = append(srv.recs, rr)
+}
+
+func (srv *testAuthSrv) count(nam string) (num int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.queries[strings.ToLower(nam)]
+}
+
+func (srv *testAuthSrv) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	q := req.Question[0]
+	qnam := strings.ToLower(q.Name)
+	srv.queries[qnam]++
+
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	zone := ""
+	for _, z := range srv.zones {
+		if dns.IsSubDomain(dns.Fqdn(z), qnam) && len(z) > len(zone) {zone = z}
+	}
+	if len(zone) == 0 {
+		msg.Rcode = dns.RcodeRefused
+		w.WriteMsg(msg)
+		return
+	}
+
+	for _, rr := range srv.deleg {
+		if dns.IsSubDomain(rr.Header().Name, qnam) {
+			msg.Authoritative = false
+			msg.Ns = append(msg.Ns, srv.deleg...)
+			msg.Extra = append(msg.Extra, srv.glue...)
+			w.WriteMsg(msg)
+			return
+		}
+	}
+
+	found := false
+	for _, rr := range srv.recs {
+		if !strings.EqualFold(rr.Header().Name, qnam) {continue}
+		found = true
+		if srv.queries[qnam] < srv.after[qnam] {continue}
+		if rr.Header().Rrtype == q.Qtype || rr.Header().Rrtype == dns.TypeCNAME {msg.Answer = append(msg.Answer, rr)}
+	}
+	if len(msg.Answer) == 0 {
+		if !found && q.Qtype != dns.TypeSOA {msg.Rcode = dns.RcodeNameError}
+		msg.Ns = append(msg.Ns, testSoa(zone))
+	}
+	w.WriteMsg(msg)
+}
+
+func newTestPropChecker(resolver string, servers ...string) (pc *PropChecker) {
+	return NewPropChecker(PropCfg{
+		Resolver: resolver,
+		NameServers: servers,
+		Timeout: 5,
+		Interval: 1,
+		MaxInterval: 2,
+	})
+}
+
+func TestPropMissing(t *testing.T) {
+
+	srv := newTestAuthSrv("example.com")
+	srv.add("_acme-challenge.example.com. 60 IN TXT \"old-value\"")
+	addr := startTestDns(t, &dns.Server{Net: "udp", Handler: srv})
+
+	pc := newTestPropChecker(addr, addr)
+
+	vals, err := pc.LookupTxt("example.com", "_acme-challenge.example.com")
+	if err != nil || len(vals) != 1 || vals[0] != "old-value" {t.Fatalf("LookupTxt: got %v %v", vals, err)}
+
+	ok, err := pc.CheckTxt("example.com", "_acme-challenge.example.com", []string{"new-value"})
+	if err != nil || ok {t.Fatalf("CheckTxt missing value: got %v %v", ok, err)}
+
+	// a missing record is not an error
+	ok, err = pc.CheckTxt("example.com", "_acme-challenge.www.example.com", []string{"new-value"})
+	if err != nil || ok {t.Fatalf("CheckTxt missing record: got %v %v", ok, err)}
+
+	pc.Cfg.Timeout = 2
+	err = pc.WaitTxt("example.com", "_acme-challenge.example.com", []string{"new-value"})
+	if err == nil {t.Fatalf("WaitTxt missing value: no error")}
+}
+
+func TestPropWaitTxt(t *testing.T) {
+
+	recNam := "_acme-challenge.example.com."
+	srv := newTestAuthSrv("example.com")
+	srv.add(recNam + " 60 IN TXT \"tok-value\"")
+	// the record appears on the third query
+	srv.after[recNam] = 3
+	addr := startTestDns(t, &dns.Server{Net: "udp", Handler: srv})
+
+	pc := newTestPropChecker(addr, addr)
+	err := pc.WaitTxt("example.com", "_acme-challenge.example.com", []string{"tok-value"})
+	if err != nil {t.Fatalf("WaitTxt: %v", err)}
+	if srv.count(recNam) < 3 {t.Fatalf("WaitTxt: %d queries, want at least 3", srv.count(recNam))}
+}
+
+func TestPropCname(t *testing.T) {
+
+	srv := newTestAuthSrv("example.com", "acme.example.net")
+	srv.add("_acme-challenge.www.example.com. 60 IN CNAME www.example.com.acme.example.net.")
+	srv.add("www.example.com.acme.example.net. 60 IN TXT \"tok-value\"")
+	addr := startTestDns(t, &dns.Server{Net: "udp", Handler: srv})
+
+	pc := newTestPropChecker(addr, addr)
+	servers, target, err := pc.TxtTarget("example.com", "_acme-challenge.www.example.com")
+	if err != nil {t.Fatalf("TxtTarget: %v", err)}
+	if target != "www.example.com.acme.example.net." || len(servers) != 1 {t.Fatalf("TxtTarget: got %v %s", servers, target)}
+
+	ok, err := pc.CheckTxt("example.com", "_acme-challenge.www.example.com", []string{"tok-value"})
+	if err != nil || !ok {t.Fatalf("CheckTxt cname: got %v %v", ok, err)}
+
+	// a cname loop ends after maxTxtDelegations
+	srv.add("_acme-challenge.loop.example.com. 60 IN CNAME _acme-challenge.loop.example.com.")
+	_, _, err = pc.TxtTarget("example.com", "_acme-challenge.loop.example.com")
+	if err == nil {t.Fatalf("TxtTarget cname loop: no error")}
+}
+
+func TestPropReferral(t *testing.T) {
+
+	// the responder serves the delegated zone on the port of the checker
+	resp := newTestAuthSrv("_acme-challenge.www.example.com")
+	resp.add("_acme-challenge.www.example.com. 60 IN TXT \"tok-value\"")
+	respAddr := startTestDns(t, &dns.Server{Net: "udp", Handler: resp})
+	_, respPort, _ := net.SplitHostPort(respAddr)
+
+	parent := newTestAuthSrv("example.com")
+	ns, _ := dns.NewRR("_acme-challenge.www.example.com. 60 IN NS ns.responder.example.org.")
+	glue, _ := dns.NewRR("ns.responder.example.org. 60 IN A 127.0.0.1")
+	parent.deleg = []dns.RR{ns}
+	parent.glue = []dns.RR{glue}
+	parentAddr := startTestDns(t, &dns.Server{Net: "udp", Handler: parent})
+
+	pc := newTestPropChecker(parentAddr, parentAddr)
+	pc.Cfg.Port = respPort
+
+	servers, target, err := pc.TxtTarget("example.com", "_acme-challenge.www.example.com")
+	if err != nil {t.Fatalf("TxtTarget: %v", err)}
+	if len(servers) != 1 || servers[0] != respAddr || target != "_acme-challenge.www.example.com." {
+		t.Fatalf("TxtTarget: got %v %s, want [%s]", servers, target, respAddr)
+	}
+
+	vals, err := pc.LookupTxt("example.com", "_acme-challenge.www.example.com")
+	if err != nil || len(vals) != 1 || vals[0] != "tok-value" {t.Fatalf("LookupTxt referral: got %v %v", vals, err)}
+	if resp.count("_acme-challenge.www.example.com.") == 0 {t.Fatalf("LookupTxt referral: responder not queried")}
+}
@@ -0,0 +1,193 @@
+// dnsProvider.go
+// interface and registry for the dns providers that publish the acme dns-01 challenge records
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultDnsProvider = "cloudflare"
+
+// zone (domain) that is served by a dns provider
+type DnsZone struct {
+	Name string `yaml:"name"`
+	Id string `yaml:"id"`
+}
+
+// acme dns-01 challenge record
+// Name is the fully qualified record name (_acme-challenge.domain)
+// Zone is the closest enclosing zone of the domain
+// RecId is the provider's id of the record and is set by Present
+type ChalRec struct {
+	Domain string `yaml:"domain"`
+	Zone DnsZone `yaml:"zone"`
+	Name string `yaml:"name"`
+	// name of the record relative to the zone
+	RelName string `yaml:"relName"`
+	Value string `yaml:"value"`
+	RecId string `yaml:"recId"`
+	// expiry of the authorization; the record is not needed afterwards
+	Exp time.Time `yaml:"expire"`
+}
+
+// interface that each dns provider has to implement
+type DNSProvider interface {
+	// name under which the provider is registered
+	Name() string
+	// zones that can be edited with the provider
+	Zones() (zones []DnsZone, err error)
+	// creates the challenge record and sets rec.RecId
+	Present(rec *ChalRec) (err error)
+	// removes the challenge record
+	CleanUp(rec *ChalRec) (err error)
+	// lists all challenge records of a zone
+	ListChalRecs(zone DnsZone) (recs []ChalRec, err error)
+}
+
+// function that creates a provider from the directories of certLibObj
+type DnsProviderFactory func(certObj *certLibObj) (prov DNSProvider, err error)
+
+var dnsProviders = map[string]DnsProviderFactory{}
+
+// function that adds a dns provider to the registry
+func RegisterDnsProvider(provNam string, factory DnsProviderFactory) {
+	if len(provNam) == 0 || factory == nil {return}
+	dnsProviders[strings.ToLower(provNam)] = factory
+}
+
+// function that creates the dns provider registered under provNam
+func NewDnsProvider(provNam string, certObj *certLibObj) (prov DNSProvider, err error) {
+
+	if len(provNam) == 0 {provNam = DefaultDnsProvider}
+
+	factory, ok := dnsProviders[strings.ToLower(provNam)]
+	if !ok {return nil, fmt.Errorf("no dns provider registered with name: %s! available: %v", provNam, DnsProviderNames())}
+
+	prov, err = factory(certObj)
+	if err != nil {return nil, fmt.Errorf("dns provider %s: %v", provNam, err)}
+	return prov, nil
+}
+
+// function that returns the names of all registered dns providers
+func DnsProviderNames() (names []string) {
+	for nam := range dnsProviders {
+		names = append(names, nam)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// function that selects the dns provider name
+// the csr list takes precedence over the account file
+func GetDnsProviderNam(csrList *CsrList, leAcnt *LEObj) (provNam string) {
+
+	if csrList != nil && len(csrList.DnsProvider) > 0 {return csrList.DnsProvider}
+	if leAcnt != nil && len(leAcnt.DnsProvider) > 0 {return leAcnt.DnsProvider}
+	return DefaultDnsProvider
+}
+
+// function that finds the closest enclosing zone of a domain in the zone list
+// a wildcard domain is matched with its base domain
+func MatchZone(domain string, zones []DnsZone) (zone DnsZone, ok bool) {
+
+	domain = strings.ToLower(strings.TrimSuffix(BaseDomain(domain), "."))
+	best := -1
+	for i:=0; i< len(zones); i++ {
+		zoneNam := strings.ToLower(strings.TrimSuffix(zones[i].Name, "."))
+		if domain != zoneNam && !strings.HasSuffix(domain, "." + zoneNam) {continue}
+		if best < 0 || len(zoneNam) > len(zones[best].Name) {best = i}
+	}
+	if best < 0 {return zone, false}
+	return zones[best], true
+}
+
+// function that returns the name of the challenge record for a domain
+// *.example.com and example.com share the record _acme-challenge.example.com
+func ChalRecName(domain string) (recNam string) {
+	return "_acme-challenge." + BaseDomain(domain)
+}
+
+// function that returns the values of all challenge records with the name recNam
+func ChalRecVals(recs []ChalRec, recNam string) (vals []string) {
+
+	for i:=0; i< len(recs); i++ {
+		if recs[i].Name == recNam && len(recs[i].Value) > 0 {vals = append(vals, recs[i].Value)}
+	}
+	return vals
+}
+
+// function that returns true if the txt records contain the value val
+func ContainsTxt(txtrecs []string, val string) (ok bool) {
+
+	for _, txt := range txtrecs {
+		if txt == val {return true}
+	}
+	return false
+}
+
+// function that returns true if the txt records contain a value that is not in vals
+// such a record is a left-over of an earlier challenge
+func HasOldTxt(txtrecs []string, vals []string) (ok bool) {
+
+	for _, txt := range txtrecs {
+		if len(txt) == 0 {continue}
+		if !ContainsTxt(vals, txt) {return true}
+	}
+	return false
+}
+
+// function that creates challenge records for all domains of a csr list
+// the records do not yet have a value
+// domains that do not use the dns-01 challenge only have the field Domain set
+func GetChalRecs(csrList *CsrList, zones []DnsZone) (recs []ChalRec, err error) {
+
+	numAcmeDom := len(csrList.Domains)
+	recs = make([]ChalRec, numAcmeDom)
+
+	missing := []string{}
+	for i:=0; i< numAcmeDom; i++ {
+		domain := csrList.Domains[i].Domain
+		if GetChalType(csrList.Domains[i]) != ChalDns01 {
+			recs[i].Domain = domain
+			continue
+		}
+		zone, err := FindZone(domain, zones)
+		if err != nil {
+			missing = append(missing, domain)
+			continue
+		}
+		recs[i].Domain = domain
+		recs[i].Zone = zone
+		recs[i].Name = ChalRecName(domain)
+		recs[i].RelName = RelRecName(recs[i].Name, zone.Name)
+		recs[i].RecId = csrList.Domains[i].ChalRecId
+		recs[i].Value = csrList.Domains[i].TokVal
+		recs[i].Exp = csrList.Domains[i].TokExp
+	}
+	if len(missing) > 0 {return recs, fmt.Errorf("no enclosing zone found for domains: %v", missing)}
+
+	return recs, nil
+}
+
+func PrintChalRecs(recs []ChalRec) {
+
+	fmt.Printf("*************** Challenge Records: %d ***************\n", len(recs))
+	for i:=0; i< len(recs); i++ {
+		rec := recs[i]
+		fmt.Printf("rec[%d]: %s\n", i+1, rec.Name)
+		fmt.Printf("    relName: %s\n", rec.RelName)
+		fmt.Printf("    domain: %s\n", rec.Domain)
+		fmt.Printf("    zone:   %s id: %s\n", rec.Zone.Name, rec.Zone.Id)
+		fmt.Printf("    value:  %s\n", rec.Value)
+		fmt.Printf("    recId:  %s\n", rec.RecId)
+	}
+	fmt.Printf("************* End Challenge Records *****************\n")
+}
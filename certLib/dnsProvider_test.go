@@ -0,0 +1,190 @@
+// dnsProvider_test.go
+// tests of the zone matching, the challenge records of a csr list and the memory provider
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// function that starts a dns server on 127.0.0.1 and returns its address
+// the server is shut down at the end of the test
+func startTestDns(t *testing.T, srv *dns.Server) (addr string) {
+
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {t.Fatalf("ListenPacket: %v", err)}
+	srv.PacketConn = pc
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	srv.NotifyStartedFunc = wg.Done
+	go srv.ActivateAndServe()
+	wg.Wait()
+	t.Cleanup(func() {srv.Shutdown()})
+	return pc.LocalAddr().String()
+}
+
+// function that returns a SOA record of zone
+func testSoa(zone string) (soa *dns.SOA) {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{Name: dns.Fqdn(zone), Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+		Ns: "ns1." + dns.Fqdn(zone),
+		Mbox: "hostmaster." + dns.Fqdn(zone),
+		Serial: 1,
+		Refresh: 3600,
+		Retry: 600,
+		Expire: 86400,
+		Minttl: 60,
+	}
+}
+
+func TestMatchZone(t *testing.T) {
+
+	zones := []DnsZone{{Name: "example.com", Id: "1"}, {Name: "eu.example.com", Id: "2"}, {Name: "example.org.", Id: "3"}}
+
+	tests := []struct {
+		domain string
+		zoneNam string
+		ok bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"*.example.com", "example.com", true},
+		{"api.eu.example.com", "eu.example.com", true},
+		{"*.eu.example.com", "eu.example.com", true},
+		{"WWW.Example.ORG", "example.org.", true},
+		{"badexample.com", "", false},
+		{"example.net", "", false},
+	}
+
+	for _, tst := range tests {
+		zone, ok := MatchZone(tst.domain, zones)
+		if ok != tst.ok || zone.Name != tst.zoneNam {
+			t.Errorf("MatchZone(%s): got %q %v, want %q %v", tst.domain, zone.Name, ok, tst.zoneNam, tst.ok)
+		}
+	}
+}
+
+func TestFindZone(t *testing.T) {
+
+	// the server knows the zone sub.example.net, which is not in the zone list
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		msg := new(dns.Msg)
+		msg.SetReply(req)
+		if dns.IsSubDomain("sub.example.net.", req.Question[0].Name) {
+			msg.Ns = append(msg.Ns, testSoa("sub.example.net"))
+		} else {
+			msg.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(msg)
+	})
+	addr := startTestDns(t, &dns.Server{Net: "udp", Handler: mux})
+
+	oldResolver := SoaResolver
+	SoaResolver = addr
+	defer func() {SoaResolver = oldResolver}()
+
+	zones := []DnsZone{{Name: "example.com", Id: "1"}, {Name: "sub.example.net", Id: "2"}}
+
+	// zone list
+	zone, err := FindZone("www.example.com", zones)
+	if err != nil || zone.Id != "1" {t.Fatalf("FindZone www.example.com: got %v %v", zone, err)}
+
+	// soa lookup that finds a zone of the list
+	zone, err = FindZone("a.b.sub.example.net", zones[:1])
+	if err != nil || zone.Name != "sub.example.net" || len(zone.Id) > 0 {t.Fatalf("FindZone soa: got %v %v", zone, err)}
+
+	// soa lookup of a zone in the list returns the entry of the list
+	zone, err = FindZone("a.sub.example.net", []DnsZone{{Name: "SUB.example.net", Id: "9"}, {Name: "example.com"}})
+	if err != nil || zone.Id != "9" {t.Fatalf("FindZone soa in list: got %v %v", zone, err)}
+
+	// no zone
+	_, err = FindZone("www.example.org", zones)
+	if err == nil {t.Fatalf("FindZone www.example.org: no error")}
+}
+
+func TestGetChalRecs(t *testing.T) {
+
+	zones := []DnsZone{{Name: "example.com", Id: "1"}, {Name: "eu.example.com", Id: "2"}}
+	csrList := &CsrList{Domains: []CsrDat{
+		{Domain: "example.com"},
+		{Domain: "*.example.com"},
+		{Domain: "api.eu.example.com"},
+		{Domain: "www.example.com", ChalType: ChalHttp01},
+	}}
+	csrList.Domains[0].ChalRecId = "rec-1"
+	csrList.Domains[0].TokVal = "val-1"
+
+	recs, err := GetChalRecs(csrList, zones)
+	if err != nil {t.Fatalf("GetChalRecs: %v", err)}
+	if len(recs) != 4 {t.Fatalf("GetChalRecs: got %d records, want 4", len(recs))}
+
+	want := []ChalRec{
+		{Domain: "example.com", Zone: zones[0], Name: "_acme-challenge.example.com", RelName: "_acme-challenge", RecId: "rec-1", Value: "val-1"},
+		{Domain: "*.example.com", Zone: zones[0], Name: "_acme-challenge.example.com", RelName: "_acme-challenge"},
+		{Domain: "api.eu.example.com", Zone: zones[1], Name: "_acme-challenge.api.eu.example.com", RelName: "_acme-challenge.api"},
+		{Domain: "www.example.com"},
+	}
+	for i := range want {
+		if recs[i] != want[i] {t.Errorf("rec[%d]: got %+v, want %+v", i, recs[i], want[i])}
+	}
+
+	// a domain without a zone is an error
+	csrList.Domains = append(csrList.Domains, CsrDat{Domain: "example.invalid"})
+	oldResolver := SoaResolver
+	SoaResolver = "127.0.0.1:1"
+	defer func() {SoaResolver = oldResolver}()
+	_, err = GetChalRecs(csrList, zones)
+	if err == nil {t.Fatalf("GetChalRecs example.invalid: no error")}
+}
+
+func TestMemDnsProvider(t *testing.T) {
+
+	prov := NewMemDnsProvider("example.com", "example.org")
+	zones, err := prov.Zones()
+	if err != nil || len(zones) != 2 {t.Fatalf("Zones: got %v %v", zones, err)}
+
+	recs := []ChalRec{
+		{Domain: "example.com", Zone: zones[0], Name: "_acme-challenge.example.com", Value: "val-1"},
+		{Domain: "*.example.com", Zone: zones[0], Name: "_acme-challenge.example.com", Value: "val-2"},
+		{Domain: "example.org", Zone: zones[1], Name: "_acme-challenge.example.org", Value: "val-3"},
+	}
+	for i := range recs {
+		err = prov.Present(&recs[i])
+		if err != nil {t.Fatalf("Present %s: %v", recs[i].Domain, err)}
+		if len(recs[i].RecId) == 0 {t.Fatalf("Present %s: no record id", recs[i].Domain)}
+	}
+	if recs[0].RecId == recs[1].RecId {t.Fatalf("Present: duplicate record id %s", recs[0].RecId)}
+
+	list, err := prov.ListChalRecs(zones[0])
+	if err != nil || len(list) != 2 {t.Fatalf("ListChalRecs %s: got %d %v", zones[0].Name, len(list), err)}
+	vals := prov.LookupTxt("_acme-challenge.example.com")
+	if !ContainsTxt(vals, "val-1") || !ContainsTxt(vals, "val-2") {t.Fatalf("LookupTxt: got %v", vals)}
+
+	for i := range recs {
+		err = prov.CleanUp(&recs[i])
+		if err != nil {t.Fatalf("CleanUp %s: %v", recs[i].Domain, err)}
+	}
+	for _, zone := range zones {
+		list, err = prov.ListChalRecs(zone)
+		if err != nil || len(list) != 0 {t.Fatalf("ListChalRecs %s after CleanUp: got %d %v", zone.Name, len(list), err)}
+	}
+
+	// a removed record cannot be removed again
+	err = prov.CleanUp(&recs[0])
+	if err == nil {t.Fatalf("CleanUp twice: no error")}
+
+	// the provider is registered
+	regProv, err := NewDnsProvider("memory", nil)
+	if err != nil || regProv.Name() != "memory" {t.Fatalf("NewDnsProvider memory: got %v %v", regProv, err)}
+}
@@ -0,0 +1,450 @@
+// dnsResponder.go
+// authoritative dns responder that answers the TXT queries for the acme dns-01 challenge
+// the challenge tokens are written into a token file by the responder dns provider
+// and are served by the responder until they expire
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+// delegation of the domain example.com to the responder:
+// - NS:    _acme-challenge.example.com NS  <responder host>
+// - CNAME: _acme-challenge.example.com CNAME example.com.<responder zone>
+//
+
+package certLib
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	yaml "github.com/goccy/go-yaml"
+)
+
+// content of the yaml file responder.yaml
+type ResponderCfg struct {
+	// listen address of the responder; default :53
+	Addr string `yaml:"addr"`
+	// zone delegated to the responder for CNAME delegation (optional)
+	Zone string `yaml:"zone"`
+	// host name of the responder used in the NS and SOA records
+	NsName string `yaml:"nsName"`
+	// mailbox of the SOA record
+	Mbox string `yaml:"mbox"`
+	TTL uint32 `yaml:"ttl"`
+	// domains whose challenge records are delegated to the responder
+	Zones []string `yaml:"zones"`
+}
+
+// challenge token served by the responder
+type ChalTok struct {
+	Name string `yaml:"name"`
+	Value string `yaml:"value"`
+	Issue time.Time `yaml:"issue"`
+	Exp time.Time `yaml:"expire"`
+}
+
+type ChalTokList struct {
+	Updated time.Time `yaml:"updated"`
+	Toks []ChalTok `yaml:"tokens"`
+}
+
+type ChalResponder struct {
+	Cfg ResponderCfg
+	TokFilnam string
+	Dbg bool
+	mu sync.Mutex
+	toks []ChalTok
+	tokMod time.Time
+	servers []*dns.Server
+}
+
+type responderProvider struct {
+	cfg ResponderCfg
+	tokFilnam string
+}
+
+func init() {
+	RegisterDnsProvider("responder", newResponderProvider)
+}
+
+// function that reads the responder configuration file
+func ReadResponderFil(inFilnam string) (cfg *ResponderCfg, err error) {
+
+	bytData, err := os.ReadFile(inFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	cfg = &ResponderCfg{}
+	err = yaml.Unmarshal(bytData, cfg)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+
+	if len(cfg.Addr) == 0 {cfg.Addr = ":53"}
+	if cfg.TTL == 0 {cfg.TTL = 30}
+	return cfg, nil
+}
+
+// function that reads the token file
+// a missing file is an empty token list
+func ReadChalTokFil(tokFilnam string) (tokList *ChalTokList, err error) {
+
+	tokList = &ChalTokList{}
+	bytData, err := os.ReadFile(tokFilnam)
+	if err != nil {
+		if os.IsNotExist(err) {return tokList, nil}
+		return nil, fmt.Errorf("os.ReadFile: %v", err)
+	}
+
+	err = yaml.Unmarshal(bytData, tokList)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+	return tokList, nil
+}
+
+// function that writes the token file
+// the file is written to a temporary file and renamed, so that the responder never reads a partial file
+func WriteChalTokFil(tokFilnam string, tokList *ChalTokList) (err error) {
+
+	tokList.Updated = time.Now()
+	tokByt, err := yaml.Marshal(tokList)
+	if err != nil {return fmt.Errorf("yaml Marshal: %v", err)}
+
+	err = os.MkdirAll(filepath.Dir(tokFilnam), 0700)
+	if err != nil {return fmt.Errorf("os.MkdirAll: %v", err)}
+
+	err = writeFileAtomic(tokFilnam, tokByt, 0600)
+	if err != nil {return fmt.Errorf("writeFileAtomic: %v", err)}
+	return nil
+}
+
+// removes expired tokens
+func purgeChalToks(toks []ChalTok, now time.Time) (valid []ChalTok) {
+	for _, tok := range toks {
+		if !tok.Exp.IsZero() && now.After(tok.Exp) {continue}
+		valid = append(valid, tok)
+	}
+	return valid
+}
+
+//
+// responder dns provider
+//
+
+func newResponderProvider(certObj *certLibObj) (prov DNSProvider, err error) {
+
+	if certObj == nil {return nil, fmt.Errorf("no certLibObj!")}
+
+	cfg, err := ReadResponderFil(certObj.ResponderFilnam)
+	if err != nil {return nil, fmt.Errorf("ReadResponderFil: %v", err)}
+
+	respProv := responderProvider{
+		cfg: *cfg,
+		tokFilnam: certObj.ChalTokFilnam,
+	}
+	return &respProv, nil
+}
+
+func (rp *responderProvider) Name() string {
+	return "responder"
+}
+
+func (rp *responderProvider) Zones() (zones []DnsZone, err error) {
+
+	zones = make([]DnsZone, len(rp.cfg.Zones))
+	for i:=0; i< len(rp.cfg.Zones); i++ {
+		zones[i].Name = strings.TrimSuffix(rp.cfg.Zones[i], ".")
+		zones[i].Id = zones[i].Name
+	}
+	return zones, nil
+}
+
+// time a run waits for the lock of the token file
+const chalTokLockWait = 30 * time.Second
+
+// adds the token to the token file; the record value serves as record id
+// runs on different csr lists share the token file; the lock keeps a run from overwriting the token of another run
+func (rp *responderProvider) Present(rec *ChalRec) (err error) {
+
+	lock, err := LockFilWait(rp.tokFilnam, chalTokLockWait)
+	if err != nil {return fmt.Errorf("LockFilWait: %v", err)}
+	defer lock.Unlock()
+
+	tokList, err := ReadChalTokFil(rp.tokFilnam)
+	if err != nil {return fmt.Errorf("ReadChalTokFil: %v", err)}
+
+	now := time.Now()
+	tok := ChalTok{
+		Name: strings.ToLower(strings.TrimSuffix(rec.Name, ".")),
+		Value: rec.Value,
+		Issue: now,
+		Exp: rec.Exp,
+	}
+	// authorizations without expiry are served for a day
+	if tok.Exp.IsZero() {tok.Exp = now.Add(24 * time.Hour)}
+
+	tokList.Toks = append(purgeChalToks(tokList.Toks, now), tok)
+	err = WriteChalTokFil(rp.tokFilnam, tokList)
+	if err != nil {return fmt.Errorf("WriteChalTokFil: %v", err)}
+
+	rec.RecId = rec.Value
+	return nil
+}
+
+func (rp *responderProvider) CleanUp(rec *ChalRec) (err error) {
+
+	lock, err := LockFilWait(rp.tokFilnam, chalTokLockWait)
+	if err != nil {return fmt.Errorf("LockFilWait: %v", err)}
+	defer lock.Unlock()
+
+	tokList, err := ReadChalTokFil(rp.tokFilnam)
+	if err != nil {return fmt.Errorf("ReadChalTokFil: %v", err)}
+
+	recNam := strings.ToLower(strings.TrimSuffix(rec.Name, "."))
+	toks := []ChalTok{}
+	for _, tok := range purgeChalToks(tokList.Toks, time.Now()) {
+		if tok.Name == recNam && tok.Value == rec.RecId {continue}
+		toks = append(toks, tok)
+	}
+	tokList.Toks = toks
+
+	err = WriteChalTokFil(rp.tokFilnam, tokList)
+	if err != nil {return fmt.Errorf("WriteChalTokFil: %v", err)}
+	return nil
+}
+
+func (rp *responderProvider) ListChalRecs(zone DnsZone) (recs []ChalRec, err error) {
+
+	tokList, err := ReadChalTokFil(rp.tokFilnam)
+	if err != nil {return nil, fmt.Errorf("ReadChalTokFil: %v", err)}
+
+	for _, tok := range purgeChalToks(tokList.Toks, time.Now()) {
+		domain := strings.TrimPrefix(tok.Name, "_acme-challenge.")
+		if domain != zone.Name && !strings.HasSuffix(domain, "." + zone.Name) {continue}
+		rec := ChalRec{
+			Domain: domain,
+			Zone: zone,
+			Name: tok.Name,
+			Value: tok.Value,
+			RecId: tok.Value,
+			Exp: tok.Exp,
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+//
+// responder server
+//
+
+// function that creates a responder
+func NewChalResponder(cfg *ResponderCfg, tokFilnam string) (resp *ChalResponder, err error) {
+
+	if cfg == nil {return nil, fmt.Errorf("no config!")}
+	if len(tokFilnam) == 0 {return nil, fmt.Errorf("no token file!")}
+
+	resp = &ChalResponder{
+		Cfg: *cfg,
+		TokFilnam: tokFilnam,
+	}
+	if len(resp.Cfg.Addr) == 0 {resp.Cfg.Addr = ":53"}
+	if resp.Cfg.TTL == 0 {resp.Cfg.TTL = 30}
+	return resp, nil
+}
+
+// function that starts the udp and tcp listeners
+func (resp *ChalResponder) Start() (err error) {
+
+	err = resp.loadToks()
+	if err != nil {return fmt.Errorf("loadToks: %v", err)}
+
+	for _, netw := range []string{"udp", "tcp"} {
+		srv := &dns.Server{
+			Addr: resp.Cfg.Addr,
+			Net: netw,
+			Handler: resp,
+		}
+		started := make(chan error, 1)
+		srv.NotifyStartedFunc = func() {started <- nil}
+		go func() {
+			err := srv.ListenAndServe()
+			if err != nil {started <- err}
+		}()
+		err = <-started
+		if err != nil {
+			resp.Stop()
+			return fmt.Errorf("%s listener on %s: %v", netw, resp.Cfg.Addr, err)
+		}
+		resp.servers = append(resp.servers, srv)
+	}
+	return nil
+}
+
+func (resp *ChalResponder) Stop() {
+	for _, srv := range resp.servers {
+		srv.Shutdown()
+	}
+	resp.servers = nil
+}
+
+// function that reloads the token file if it has changed
+func (resp *ChalResponder) loadToks() (err error) {
+
+	info, err := os.Stat(resp.TokFilnam)
+	if err != nil {
+		if os.IsNotExist(err) {
+			resp.toks = nil
+			return nil
+		}
+		return fmt.Errorf("os.Stat: %v", err)
+	}
+	if info.ModTime().Equal(resp.tokMod) {return nil}
+
+	tokList, err := ReadChalTokFil(resp.TokFilnam)
+	if err != nil {return fmt.Errorf("ReadChalTokFil: %v", err)}
+
+	resp.toks = tokList.Toks
+	resp.tokMod = info.ModTime()
+	if resp.Dbg {log.Printf("responder: loaded %d tokens\n", len(resp.toks))}
+	return nil
+}
+
+// function that maps a query name to the name of the challenge record
+// names below the responder zone are CNAME targets: example.com.<zone> -> _acme-challenge.example.com
+func (resp *ChalResponder) chalNam(qnam string) (recNam string) {
+
+	qnam = strings.ToLower(strings.TrimSuffix(qnam, "."))
+	zone := strings.ToLower(strings.TrimSuffix(resp.Cfg.Zone, "."))
+	if len(zone) > 0 && strings.HasSuffix(qnam, "." + zone) {
+		domain := strings.TrimSuffix(qnam, "." + zone)
+		if strings.Index(domain, "_acme-challenge.") == 0 {return domain}
+		return ChalRecName(domain)
+	}
+	return qnam
+}
+
+// function that returns the values of all valid tokens for a query name
+func (resp *ChalResponder) LookupToks(qnam string) (vals []string) {
+
+	resp.mu.Lock()
+	defer resp.mu.Unlock()
+
+	err := resp.loadToks()
+	if err != nil {log.Printf("responder: loadToks: %v\n", err)}
+
+	recNam := resp.chalNam(qnam)
+	now := time.Now()
+	for _, tok := range resp.toks {
+		if tok.Name != recNam {continue}
+		if !tok.Exp.IsZero() && now.After(tok.Exp) {continue}
+		vals = append(vals, tok.Value)
+	}
+	return vals
+}
+
+func (resp *ChalResponder) soa(qnam string) (rr *dns.SOA) {
+
+	nsNam := resp.Cfg.NsName
+	if len(nsNam) == 0 {nsNam = "localhost"}
+	mbox := resp.Cfg.Mbox
+	if len(mbox) == 0 {mbox = "hostmaster." + nsNam}
+
+	rr = &dns.SOA{
+		Hdr: dns.RR_Header{Name: dns.Fqdn(qnam), Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: resp.Cfg.TTL},
+		Ns: dns.Fqdn(nsNam),
+		Mbox: dns.Fqdn(mbox),
+		Serial: uint32(time.Now().Unix()),
+		Refresh: 3600,
+		Retry: 600,
+		Expire: 86400,
+		Minttl: resp.Cfg.TTL,
+	}
+	return rr
+}
+
+// dns handler
+func (resp *ChalResponder) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	if len(req.Question) != 1 {
+		msg.SetRcode(req, dns.RcodeFormatError)
+		w.WriteMsg(msg)
+		return
+	}
+
+	q := req.Question[0]
+	if resp.Dbg {log.Printf("responder: query %s %s\n", q.Name, dns.TypeToString[q.Qtype])}
+
+	vals := resp.LookupToks(q.Name)
+	switch q.Qtype {
+	case dns.TypeTXT, dns.TypeANY:
+		for _, val := range vals {
+			rr := &dns.TXT{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: resp.Cfg.TTL},
+				Txt: []string{val},
+			}
+			msg.Answer = append(msg.Answer, rr)
+		}
+	case dns.TypeSOA:
+		msg.Answer = append(msg.Answer, resp.soa(q.Name))
+	case dns.TypeNS:
+		if len(resp.Cfg.NsName) > 0 {
+			rr := &dns.NS{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: resp.Cfg.TTL},
+				Ns: dns.Fqdn(resp.Cfg.NsName),
+			}
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+
+	if len(msg.Answer) == 0 {
+		// negative answer: nxdomain if there are no tokens for the name
+		if len(vals) == 0 && q.Qtype != dns.TypeSOA && q.Qtype != dns.TypeNS {msg.Rcode = dns.RcodeNameError}
+		msg.Ns = append(msg.Ns, resp.soa(q.Name))
+	}
+
+	err := w.WriteMsg(msg)
+	if err != nil && resp.Dbg {log.Printf("responder: WriteMsg: %v\n", err)}
+}
+
+// function that removes the expired tokens from the token file
+// the file lock keeps the purge from dropping a token that a run adds at the same time
+func (resp *ChalResponder) PurgeToks() (num int, err error) {
+
+	resp.mu.Lock()
+	defer resp.mu.Unlock()
+
+	lock, err := LockFilWait(resp.TokFilnam, chalTokLockWait)
+	if err != nil {return 0, fmt.Errorf("LockFilWait: %v", err)}
+	defer lock.Unlock()
+
+	tokList, err := ReadChalTokFil(resp.TokFilnam)
+	if err != nil {return 0, fmt.Errorf("ReadChalTokFil: %v", err)}
+
+	valid := purgeChalToks(tokList.Toks, time.Now())
+	num = len(tokList.Toks) - len(valid)
+	if num == 0 {return 0, nil}
+
+	tokList.Toks = valid
+	err = WriteChalTokFil(resp.TokFilnam, tokList)
+	if err != nil {return 0, fmt.Errorf("WriteChalTokFil: %v", err)}
+	return num, nil
+}
+
+func PrintChalToks(tokList *ChalTokList) {
+
+	fmt.Printf("*************** Challenge Tokens: %d ***************\n", len(tokList.Toks))
+	for i, tok := range tokList.Toks {
+		fmt.Printf("tok[%d]: %s\n", i+1, tok.Name)
+		fmt.Printf("    value:  %s\n", tok.Value)
+		fmt.Printf("    issue:  %s\n", tok.Issue.Format(time.RFC1123))
+		fmt.Printf("    expire: %s\n", tok.Exp.Format(time.RFC1123))
+	}
+	fmt.Printf("************* End Challenge Tokens *****************\n")
+}
@@ -0,0 +1,232 @@
+// dnsRfc2136.go
+// implementation of the DNSProvider interface with RFC 2136 dynamic updates signed with TSIG
+// the provider is meant for self-hosted authoritative servers such as bind and knot
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	yaml "github.com/goccy/go-yaml"
+)
+
+// content of the yaml file rfc2136.yaml
+type Rfc2136Cfg struct {
+	// address of the primary server: host:port
+	Server string `yaml:"server"`
+	// udp or tcp; default is tcp
+	Net string `yaml:"net"`
+	KeyName string `yaml:"keyName"`
+	// base64 encoded tsig secret
+	Secret string `yaml:"secret"`
+	// hmac-sha256 (default), hmac-sha512, hmac-sha384, hmac-sha224, hmac-sha1 or hmac-md5
+	Algorithm string `yaml:"algorithm"`
+	TTL uint32 `yaml:"ttl"`
+	// seconds
+	Timeout int `yaml:"timeout"`
+	Zones []string `yaml:"zones"`
+}
+
+type rfc2136Provider struct {
+	cfg Rfc2136Cfg
+	keyName string
+	alg string
+	client *dns.Client
+}
+
+var tsigAlgs = map[string]string{
+	"hmac-md5": dns.HmacMD5,
+	"hmac-sha1": dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+func init() {
+	RegisterDnsProvider("rfc2136", newRfc2136ProviderFromFil)
+}
+
+func newRfc2136ProviderFromFil(certObj *certLibObj) (prov DNSProvider, err error) {
+
+	if certObj == nil {return nil, fmt.Errorf("no certLibObj!")}
+
+	cfg, err := ReadRfc2136Fil(certObj.Rfc2136Filnam)
+	if err != nil {return nil, fmt.Errorf("ReadRfc2136Fil: %v", err)}
+
+	return NewRfc2136Provider(cfg)
+}
+
+// function that reads the rfc2136 configuration file
+func ReadRfc2136Fil(inFilnam string) (cfg *Rfc2136Cfg, err error) {
+
+	bytData, err := os.ReadFile(inFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	cfg = &Rfc2136Cfg{}
+	err = yaml.Unmarshal(bytData, cfg)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+
+	return cfg, nil
+}
+
+// function that creates a rfc2136 provider
+func NewRfc2136Provider(cfg *Rfc2136Cfg) (prov *rfc2136Provider, err error) {
+
+	if cfg == nil {return nil, fmt.Errorf("no config!")}
+	if len(cfg.Server) == 0 {return nil, fmt.Errorf("no server address!")}
+	if len(cfg.KeyName) == 0 {return nil, fmt.Errorf("no tsig key name!")}
+	if len(cfg.Secret) == 0 {return nil, fmt.Errorf("no tsig secret!")}
+
+	algNam := strings.ToLower(cfg.Algorithm)
+	if len(algNam) == 0 {algNam = "hmac-sha256"}
+	alg, ok := tsigAlgs[strings.TrimSuffix(algNam, ".")]
+	if !ok {return nil, fmt.Errorf("unknown tsig algorithm: %s!", cfg.Algorithm)}
+
+	if cfg.TTL == 0 {cfg.TTL = 60}
+	if cfg.Timeout == 0 {cfg.Timeout = 10}
+	if len(cfg.Net) == 0 {cfg.Net = "tcp"}
+
+	// the server address may omit the port
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		cfg.Server = net.JoinHostPort(strings.Trim(cfg.Server, "[]"), "53")
+	}
+
+	keyName := dns.Fqdn(cfg.KeyName)
+	prov = &rfc2136Provider{
+		cfg: *cfg,
+		keyName: keyName,
+		alg: alg,
+		client: &dns.Client{
+			Net: cfg.Net,
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			TsigSecret: map[string]string{keyName: cfg.Secret},
+		},
+	}
+	return prov, nil
+}
+
+func (p *rfc2136Provider) Name() string {
+	return "rfc2136"
+}
+
+func (p *rfc2136Provider) Zones() (zones []DnsZone, err error) {
+
+	zones = make([]DnsZone, len(p.cfg.Zones))
+	for i:=0; i< len(p.cfg.Zones); i++ {
+		zones[i].Name = strings.TrimSuffix(p.cfg.Zones[i], ".")
+		zones[i].Id = dns.Fqdn(p.cfg.Zones[i])
+	}
+	return zones, nil
+}
+
+func (p *rfc2136Provider) txtRR(rec *ChalRec) (rr *dns.TXT) {
+
+	rr = &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name: dns.Fqdn(rec.Name),
+			Rrtype: dns.TypeTXT,
+			Class: dns.ClassINET,
+			Ttl: p.cfg.TTL,
+		},
+		Txt: []string{rec.Value},
+	}
+	return rr
+}
+
+// function that sends a signed update message to the server
+func (p *rfc2136Provider) update(msg *dns.Msg) (err error) {
+
+	msg.SetTsig(p.keyName, p.alg, 300, time.Now().Unix())
+
+	resp, _, err := p.client.Exchange(msg, p.cfg.Server)
+	if err != nil {return fmt.Errorf("dns update: %v", err)}
+	if resp == nil {return fmt.Errorf("dns update: no response!")}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("dns update: server returned %s!", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// the value of the record serves as the record id
+func (p *rfc2136Provider) Present(rec *ChalRec) (err error) {
+
+	if len(rec.Zone.Name) == 0 {return fmt.Errorf("rfc2136: no zone for %s!", rec.Name)}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(rec.Zone.Name))
+	msg.Insert([]dns.RR{p.txtRR(rec)})
+
+	err = p.update(msg)
+	if err != nil {return fmt.Errorf("rfc2136 add %s: %v", rec.Name, err)}
+
+	rec.RecId = rec.Value
+	return nil
+}
+
+func (p *rfc2136Provider) CleanUp(rec *ChalRec) (err error) {
+
+	if len(rec.Zone.Name) == 0 {return fmt.Errorf("rfc2136: no zone for %s!", rec.Name)}
+
+	delRec := *rec
+	if len(delRec.Value) == 0 {delRec.Value = delRec.RecId}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(rec.Zone.Name))
+	if len(delRec.Value) == 0 {
+		// no value: remove all txt records with that name
+		rr := p.txtRR(&delRec)
+		msg.RemoveRRset([]dns.RR{rr})
+	} else {
+		msg.Remove([]dns.RR{p.txtRR(&delRec)})
+	}
+
+	err = p.update(msg)
+	if err != nil {return fmt.Errorf("rfc2136 delete %s: %v", rec.Name, err)}
+	return nil
+}
+
+// the challenge records are retrieved with a zone transfer signed with the tsig key
+func (p *rfc2136Provider) ListChalRecs(zone DnsZone) (recs []ChalRec, err error) {
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone.Name))
+	msg.SetTsig(p.keyName, p.alg, 300, time.Now().Unix())
+
+	tr := &dns.Transfer{
+		TsigSecret: map[string]string{p.keyName: p.cfg.Secret},
+		DialTimeout: p.client.Timeout,
+		ReadTimeout: p.client.Timeout,
+	}
+	envChan, err := tr.In(msg, p.cfg.Server)
+	if err != nil {return nil, fmt.Errorf("zone transfer: %v", err)}
+
+	for env := range envChan {
+		if env.Error != nil {return nil, fmt.Errorf("zone transfer: %v", env.Error)}
+		for _, rr := range env.RR {
+			txt, ok := rr.(*dns.TXT)
+			if !ok {continue}
+			recNam := strings.TrimSuffix(txt.Hdr.Name, ".")
+			if strings.Index(recNam, "_acme-challenge.") != 0 {continue}
+			val := strings.Join(txt.Txt, "")
+			rec := ChalRec{
+				Domain: strings.TrimPrefix(recNam, "_acme-challenge."),
+				Zone: zone,
+				Name: recNam,
+				Value: val,
+				RecId: val,
+			}
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
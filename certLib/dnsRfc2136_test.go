@@ -0,0 +1,149 @@
+// dnsRfc2136_test.go
+// tests of the rfc2136 provider against a local authoritative server that checks the tsig signature
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testTsigKey = "acme-test."
+	testTsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LWtleQ=="
+)
+
+// update server that records the updates with a valid signature
+type testUpdSrv struct {
+	mu sync.Mutex
+	upds []*dns.Msg
+}
+
+func (srv *testUpdSrv) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+
+	tsig := req.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		msg.Rcode = dns.RcodeNotAuth
+		w.WriteMsg(msg)
+		return
+	}
+
+	srv.mu.Lock()
+	srv.upds = append(srv.upds, req.Copy())
+	srv.mu.Unlock()
+
+	msg.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+	w.WriteMsg(msg)
+}
+
+func (srv *testUpdSrv) last() (upd *dns.Msg) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.upds) == 0 {return nil}
+	return srv.upds[len(srv.upds)-1]
+}
+
+func startTestUpdSrv(t *testing.T) (upd *testUpdSrv, addr string) {
+
+	upd = &testUpdSrv{}
+	addr = startTestDns(t, &dns.Server{
+		Net: "udp",
+		Handler: upd,
+		TsigSecret: map[string]string{testTsigKey: testTsigSecret},
+		// the default accept function refuses updates
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {return dns.MsgAccept},
+	})
+	return upd, addr
+}
+
+// function that checks that an update of zone has one txt record with name, value and class
+func checkTestUpd(t *testing.T, upd *dns.Msg, zone string, name string, val string, class uint16) {
+
+	t.Helper()
+	if upd == nil {t.Fatalf("no update received")}
+	if upd.Opcode != dns.OpcodeUpdate {t.Fatalf("opcode: got %s", dns.OpcodeToString[upd.Opcode])}
+	if len(upd.Question) != 1 || upd.Question[0].Name != dns.Fqdn(zone) {t.Fatalf("zone: got %v", upd.Question)}
+	if len(upd.Ns) != 1 {t.Fatalf("update section: got %d records, want 1", len(upd.Ns))}
+
+	txt, ok := upd.Ns[0].(*dns.TXT)
+	if !ok {t.Fatalf("update record: got %s", upd.Ns[0])}
+	if txt.Hdr.Name != dns.Fqdn(name) || txt.Hdr.Class != class || strings.Join(txt.Txt, "") != val {
+		t.Fatalf("update record: got %s", txt)
+	}
+}
+
+func TestRfc2136Provider(t *testing.T) {
+
+	srv, addr := startTestUpdSrv(t)
+
+	prov, err := NewRfc2136Provider(&Rfc2136Cfg{
+		Server: addr,
+		Net: "udp",
+		KeyName: "acme-test",
+		Secret: testTsigSecret,
+		Zones: []string{"example.com"},
+	})
+	if err != nil {t.Fatalf("NewRfc2136Provider: %v", err)}
+
+	zones, err := prov.Zones()
+	if err != nil || len(zones) != 1 || zones[0].Name != "example.com" {t.Fatalf("Zones: got %v %v", zones, err)}
+
+	rec := ChalRec{
+		Domain: "www.example.com",
+		Zone: zones[0],
+		Name: "_acme-challenge.www.example.com",
+		Value: "tok-value",
+	}
+	err = prov.Present(&rec)
+	if err != nil {t.Fatalf("Present: %v", err)}
+	if rec.RecId != rec.Value {t.Fatalf("Present: record id %q, want the value", rec.RecId)}
+	checkTestUpd(t, srv.last(), "example.com", rec.Name, "tok-value", dns.ClassINET)
+
+	// the record is removed by its value; the record id is used if the value was not saved
+	rec.Value = ""
+	err = prov.CleanUp(&rec)
+	if err != nil {t.Fatalf("CleanUp: %v", err)}
+	checkTestUpd(t, srv.last(), "example.com", rec.Name, "tok-value", dns.ClassNONE)
+}
+
+func TestRfc2136BadKey(t *testing.T) {
+
+	srv, addr := startTestUpdSrv(t)
+
+	prov, err := NewRfc2136Provider(&Rfc2136Cfg{
+		Server: addr,
+		Net: "udp",
+		KeyName: "acme-test",
+		Secret: "d3JvbmctZDNKdmJtY3RkM0p2Ym1jdGQzSnZibWM=",
+		Timeout: 2,
+		Zones: []string{"example.com"},
+	})
+	if err != nil {t.Fatalf("NewRfc2136Provider: %v", err)}
+
+	rec := ChalRec{
+		Zone: DnsZone{Name: "example.com"},
+		Name: "_acme-challenge.example.com",
+		Value: "tok-value",
+	}
+	err = prov.Present(&rec)
+	if err == nil {t.Fatalf("Present with bad key: no error")}
+	if srv.last() != nil {t.Fatalf("Present with bad key: update accepted")}
+	if len(rec.RecId) > 0 {t.Fatalf("Present with bad key: record id set")}
+
+	// a provider without a key is refused
+	_, err = NewRfc2136Provider(&Rfc2136Cfg{Server: addr, KeyName: "acme-test"})
+	if err == nil {t.Fatalf("NewRfc2136Provider without secret: no error")}
+	_, err = NewRfc2136Provider(&Rfc2136Cfg{Server: addr, KeyName: "acme-test", Secret: testTsigSecret, Algorithm: "hmac-sha3"})
+	if err == nil {t.Fatalf("NewRfc2136Provider with unknown algorithm: no error")}
+}
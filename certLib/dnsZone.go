@@ -0,0 +1,102 @@
+// dnsZone.go
+// functions that find the zone enclosing a domain
+// the zone list of the dns provider is searched first; the fallback is a SOA lookup
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// address (host:port) of the resolver used for SOA lookups
+// if empty, the first name server of /etc/resolv.conf is used
+var SoaResolver string
+
+func soaResolverAddr() (addr string, err error) {
+
+	if len(SoaResolver) > 0 {return SoaResolver, nil}
+
+	cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {return "", fmt.Errorf("resolv.conf: %v", err)}
+	if len(cfg.Servers) == 0 {return "", fmt.Errorf("resolv.conf: no name server!")}
+	return net.JoinHostPort(cfg.Servers[0], cfg.Port), nil
+}
+
+// function that returns the name of the zone enclosing domain from the SOA records
+// a SOA query for a name inside a zone returns the SOA of the zone in the authority section
+func LookupZoneSOA(domain string) (zoneNam string, err error) {
+
+	srvAddr, err := soaResolverAddr()
+	if err != nil {return "", fmt.Errorf("soaResolverAddr: %v", err)}
+	return lookupZoneSOAAt(srvAddr, domain)
+}
+
+// function that returns the name of the zone enclosing domain from the SOA records of the resolver srvAddr
+func lookupZoneSOAAt(srvAddr string, domain string) (zoneNam string, err error) {
+
+	client := dns.Client{Timeout: 5 * time.Second}
+	nam := dns.Fqdn(strings.ToLower(BaseDomain(domain)))
+	for {
+		msg := new(dns.Msg)
+		msg.SetQuestion(nam, dns.TypeSOA)
+		msg.RecursionDesired = true
+
+		resp, _, err := client.Exchange(msg, srvAddr)
+		if err != nil {return "", fmt.Errorf("soa query %s: %v", nam, err)}
+
+		if resp.Rcode == dns.RcodeSuccess || resp.Rcode == dns.RcodeNameError {
+			for _, rr := range append(resp.Answer, resp.Ns...) {
+				soa, ok := rr.(*dns.SOA)
+				if ok {return strings.ToLower(strings.TrimSuffix(soa.Hdr.Name, ".")), nil}
+			}
+		}
+
+		// try the parent name
+		idx := strings.Index(nam, ".")
+		if idx < 0 || idx == len(nam)-1 {break}
+		nam = nam[idx+1:]
+	}
+	return "", fmt.Errorf("no soa record found for %s!", domain)
+}
+
+// function that finds the zone enclosing domain
+// the closest enclosing zone of the zone list is used; otherwise the zone is found with a SOA lookup
+// a zone found with the SOA lookup that is not in the zone list has no Id
+func FindZone(domain string, zones []DnsZone) (zone DnsZone, err error) {
+
+	zone, ok := MatchZone(domain, zones)
+	if ok {return zone, nil}
+
+	zoneNam, err := LookupZoneSOA(domain)
+	if err != nil {return zone, fmt.Errorf("domain %s not in zone list: %v", domain, err)}
+
+	for i:=0; i< len(zones); i++ {
+		if strings.ToLower(zones[i].Name) == zoneNam {return zones[i], nil}
+	}
+	zone.Name = zoneNam
+	return zone, nil
+}
+
+// function that returns the name of a record relative to its zone
+// _acme-challenge.api.eu.example.com in zone example.com is _acme-challenge.api.eu
+func RelRecName(recNam string, zoneNam string) (relNam string) {
+
+	recNam = strings.TrimSuffix(recNam, ".")
+	zoneNam = strings.TrimSuffix(zoneNam, ".")
+	if strings.EqualFold(recNam, zoneNam) {return "@"}
+
+	suffix := "." + zoneNam
+	if len(recNam) > len(suffix) && strings.EqualFold(recNam[len(recNam)-len(suffix):], suffix) {
+		return recNam[:len(recNam)-len(suffix)]
+	}
+	return recNam
+}
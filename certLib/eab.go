@@ -0,0 +1,66 @@
+// eab.go
+// external account binding (RFC 8555 section 7.3.4)
+// CAs such as ZeroSSL, Google Trust Services or step-ca only register accounts bound to an account of the CA
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+)
+
+// function that decodes the hmac key of an external account binding
+// CAs provide the key base64url encoded; some add padding or use the standard alphabet
+func DecodeEabHmac(hmacStr string) (key []byte, err error) {
+
+	hmacStr = strings.TrimSpace(hmacStr)
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.StdEncoding} {
+		key, err = enc.DecodeString(hmacStr)
+		if err == nil {return key, nil}
+	}
+	return nil, fmt.Errorf("hmac key is not base64 encoded!")
+}
+
+// function that returns the external account binding of a key id and hmac key
+// an empty key id returns nil
+func GetEab(eabKid string, eabHmac string) (eab *acme.ExternalAccountBinding, err error) {
+
+	if len(eabKid) == 0 {
+		if len(eabHmac) > 0 {return nil, fmt.Errorf("eab hmac key without key id!")}
+		return nil, nil
+	}
+	if len(eabHmac) == 0 {return nil, fmt.Errorf("eab key id %s without hmac key!", eabKid)}
+
+	key, err := DecodeEabHmac(eabHmac)
+	if err != nil {return nil, fmt.Errorf("DecodeEabHmac: %v", err)}
+
+	eab = &acme.ExternalAccountBinding{
+		KID: eabKid,
+		Key: key,
+	}
+	return eab, nil
+}
+
+// function that converts an acme account into its yaml version
+func NewJsAcnt(acnt *acme.Account) (jsAcnt *JsAcnt) {
+
+	jsAcnt = &JsAcnt{
+		URI: acnt.URI,
+		Contact: acnt.Contact,
+		Status: acnt.Status,
+		OrdersURL: acnt.OrdersURL,
+		AgreedTerms: acnt.AgreedTerms,
+		CurrentTerms: acnt.CurrentTerms,
+		Authz: acnt.Authz,
+		Authorizations: acnt.Authorizations,
+		Certificates: acnt.Certificates,
+	}
+	return jsAcnt
+}
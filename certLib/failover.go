@@ -0,0 +1,82 @@
+// failover.go
+// functions that decide whether the issuance moves on to the next CA account
+// the accounts of a csr list are tried in order: the account of the csr list followed by the fallback accounts
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// function that returns the accounts used for the issuance in order
+// duplicate accounts are removed
+func IssueAccounts(acntNam string, fallback []string) (acntList []string) {
+
+	for _, nam := range append([]string{acntNam}, fallback...) {
+		dup := false
+		for _, old := range acntList {
+			if old == nam {dup = true}
+		}
+		if !dup {acntList = append(acntList, nam)}
+	}
+	return acntList
+}
+
+// function that classifies an error of the acme server
+// failover is true for errors that another CA may not have: outages, rate limits, CA policies and account problems
+// errors of the csr or of the domains are not solved by another CA
+func ClassifyAcmeErr(err error) (class string, failover bool) {
+
+	if err == nil {return "", false}
+
+	var orderErr *acme.OrderError
+	if errors.As(err, &orderErr) {return "orderInvalid", false}
+	var authErr *acme.AuthorizationError
+	if errors.As(err, &authErr) {return "authorization", false}
+
+	var acmeErr *acme.Error
+	if errors.As(err, &acmeErr) {
+		probType := strings.TrimPrefix(acmeErr.ProblemType, "urn:ietf:params:acme:error:")
+		switch probType {
+		case "rateLimited", "serverInternal", "caa", "rejectedIdentifier", "unauthorized",
+			"accountDoesNotExist", "externalAccountRequired", "userActionRequired":
+			return probType, true
+		}
+		if acmeErr.StatusCode >= 500 {return "server", true}
+		if acmeErr.StatusCode == 429 {return "rateLimited", true}
+		if len(probType) == 0 {probType = "acme"}
+		return probType, false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {return "timeout", true}
+	var netErr net.Error
+	if errors.As(err, &netErr) {return "network", true}
+	return "unknown", false
+}
+
+// function that removes the challenge data of an order from the csr list, like CleanCsrFil without saving the state
+// the next CA starts with a fresh order
+func ResetCsrOrder(csrList *CsrList) {
+
+	csrList.OrderUrl = ""
+	for i:=0; i< len(csrList.Domains); i++ {
+		dom := &csrList.Domains[i]
+		dom.ChalRecId = ""
+		dom.Token = ""
+		dom.TokVal = ""
+		dom.TokUrl = ""
+		dom.TokIssue = time.Time{}
+		dom.TokExp = time.Time{}
+		dom.OrderUrl = ""
+	}
+}
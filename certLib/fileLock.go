@@ -0,0 +1,114 @@
+// fileLock.go
+// atomic file writes and advisory locks of the csr and account files
+// a lock is held on the file <name>.lock for the duration of a run; a second run on the same file fails
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// advisory lock of a file
+type FileLock struct {
+	Filnam string
+	fil *os.File
+}
+
+// function that writes a file to a temporary file in the same directory and renames it
+// the file is either the old or the new file after a crash, never a truncated one
+func writeFileAtomic(filnam string, data []byte, perm os.FileMode) (err error) {
+
+	dir := filepath.Dir(filnam)
+	tmpFil, err := os.CreateTemp(dir, filepath.Base(filnam) + ".tmp*")
+	if err != nil {return fmt.Errorf("os.CreateTemp: %v", err)}
+	tmpFilnam := tmpFil.Name()
+	defer os.Remove(tmpFilnam)
+
+	_, err = tmpFil.Write(data)
+	if err == nil {err = tmpFil.Chmod(perm)}
+	if err == nil {err = tmpFil.Sync()}
+	if err2 := tmpFil.Close(); err == nil {err = err2}
+	if err != nil {return fmt.Errorf("write %s: %v", tmpFilnam, err)}
+
+	err = os.Rename(tmpFilnam, filnam)
+	if err != nil {return fmt.Errorf("os.Rename: %v", err)}
+
+	// the rename is durable once the directory is synced
+	dirFil, err := os.Open(dir)
+	if err != nil {return nil}
+	dirFil.Sync()
+	dirFil.Close()
+	return nil
+}
+
+// function that locks the file filnam for this process
+// the lock fails at once if another process holds it
+func LockFil(filnam string) (lock *FileLock, err error) {
+
+	lockFilnam := filnam + ".lock"
+	fil, err := os.OpenFile(lockFilnam, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {return nil, fmt.Errorf("os.OpenFile: %v", err)}
+
+	err = lockFd(fil)
+	if err != nil {
+		pidByt, _ := os.ReadFile(lockFilnam)
+		fil.Close()
+		pid := strings.TrimSpace(string(pidByt))
+		if len(pid) == 0 {pid = "unknown"}
+		return nil, fmt.Errorf("%s is locked by another run (pid %s): %v", filnam, pid, err)
+	}
+
+	// the pid of the lock holder is only informational
+	fil.Truncate(0)
+	fil.WriteAt([]byte(strconv.Itoa(os.Getpid()) + "\n"), 0)
+	return &FileLock{Filnam: lockFilnam, fil: fil}, nil
+}
+
+// function that locks the file filnam and waits up to timeout if another process holds the lock
+// for files that several runs change briefly, e.g. the token file of the dns responder
+func LockFilWait(filnam string, timeout time.Duration) (lock *FileLock, err error) {
+
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err = LockFil(filnam)
+		if err == nil || time.Now().After(deadline) {return lock, err}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// function that releases the lock
+// the lock file is kept; removing it would allow two processes to hold locks on different files
+func (lock *FileLock) Unlock() (err error) {
+
+	if lock == nil || lock.fil == nil {return nil}
+	lock.fil.Truncate(0)
+	err = unlockFd(lock.fil)
+	lock.fil.Close()
+	lock.fil = nil
+	if err != nil {return fmt.Errorf("unlock %s: %v", lock.Filnam, err)}
+	return nil
+}
+
+// function that locks the csr list of a run
+// only one run may publish the challenges of and save the state of a csr list at a time
+// the lock is held on the state file, so that the csr directory may be read-only
+func LockCsrFil(csrFilnam string) (lock *FileLock, err error) {
+
+	stateFilnam, err := CsrStateFilnam(csrFilnam)
+	if err != nil {return nil, err}
+	err = os.MkdirAll(filepath.Dir(stateFilnam), 0755)
+	if err != nil {return nil, fmt.Errorf("os.MkdirAll: %v", err)}
+
+	lock, err = LockFil(stateFilnam)
+	if err != nil {return nil, fmt.Errorf("csr list %s in use: %v", CsrNam(csrFilnam), err)}
+	return lock, nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+// fileLock_other.go
+// systems without flock: the lock is not enforced
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"os"
+)
+
+func lockFd(fil *os.File) (err error) {return nil}
+
+func unlockFd(fil *os.File) (err error) {return nil}
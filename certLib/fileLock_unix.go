@@ -0,0 +1,23 @@
+//go:build unix
+
+// fileLock_unix.go
+// advisory locks with flock
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFd(fil *os.File) (err error) {
+	return syscall.Flock(int(fil.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFd(fil *os.File) (err error) {
+	return syscall.Flock(int(fil.Fd()), syscall.LOCK_UN)
+}
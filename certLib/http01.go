@@ -0,0 +1,218 @@
+// http01.go
+// solver for the acme http-01 challenge
+// the key authorization is either served by an own http listener or written into a webroot directory
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChalDns01 = "dns-01"
+	ChalHttp01 = "http-01"
+)
+
+const http01Path = "/.well-known/acme-challenge/"
+
+// http-01 configuration of a csr list
+// if Webroot is set, the key authorizations are written to Webroot/.well-known/acme-challenge
+// otherwise the solver runs its own listener on Addr (default :80)
+type Http01Cfg struct {
+	Addr string `yaml:"addr"`
+	Webroot string `yaml:"webroot"`
+}
+
+type Http01Solver struct {
+	Cfg Http01Cfg
+	Dbg bool
+	mu sync.Mutex
+	toks map[string]string
+	srv *http.Server
+}
+
+// function that returns the challenge type of a domain; default is dns-01
+func GetChalType(csrDat CsrDat) (chalType string) {
+
+	chalType = strings.ToLower(csrDat.ChalType)
+	if len(chalType) == 0 {chalType = ChalDns01}
+	return chalType
+}
+
+// function that checks the challenge types of a csr list
+func CheckChalTypes(csrList *CsrList) (err error) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		chalType := GetChalType(csrList.Domains[i])
+		switch chalType {
+		case ChalDns01, ChalHttp01, ChalTlsAlpn01:
+		default:
+			return fmt.Errorf("domain %s: unknown challenge type: %s!", csrList.Domains[i].Domain, chalType)
+		}
+		// wildcard domains can only be validated with dns-01
+		if IsWildcard(csrList.Domains[i].Domain) && chalType != ChalDns01 {
+			return fmt.Errorf("domain %s: wildcard domains require the dns-01 challenge!", csrList.Domains[i].Domain)
+		}
+	}
+	return nil
+}
+
+// function that returns true if a domain of the csr list uses the challenge type chalType
+func UsesChalType(csrList *CsrList, chalType string) (ok bool) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		if GetChalType(csrList.Domains[i]) == chalType {return true}
+	}
+	return false
+}
+
+// function that creates a http-01 solver
+func NewHttp01Solver(cfg Http01Cfg) (solver *Http01Solver) {
+
+	if len(cfg.Webroot) == 0 && len(cfg.Addr) == 0 {cfg.Addr = ":80"}
+	solver = &Http01Solver{
+		Cfg: cfg,
+		toks: make(map[string]string),
+	}
+	return solver
+}
+
+// function that starts the listener; nothing to do for a webroot
+func (solver *Http01Solver) Start() (err error) {
+
+	if len(solver.Cfg.Webroot) > 0 {
+		info, err := os.Stat(solver.Cfg.Webroot)
+		if err != nil {return fmt.Errorf("webroot: %v", err)}
+		if !info.IsDir() {return fmt.Errorf("webroot %s is not a directory!", solver.Cfg.Webroot)}
+		return nil
+	}
+
+	if solver.srv != nil {return nil}
+
+	ln, err := net.Listen("tcp", solver.Cfg.Addr)
+	if err != nil {return fmt.Errorf("net.Listen: %v", err)}
+
+	solver.srv = &http.Server{
+		Handler: solver,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := solver.srv.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {log.Printf("http01 Serve: %v\n", err)}
+	}()
+	return nil
+}
+
+func (solver *Http01Solver) Stop() (err error) {
+
+	if solver.srv == nil {return nil}
+	err = solver.srv.Close()
+	solver.srv = nil
+	if err != nil {return fmt.Errorf("http01 Close: %v", err)}
+	return nil
+}
+
+// http handler that serves the key authorizations
+func (solver *Http01Solver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	if solver.Dbg {log.Printf("http01: %s %s host: %s\n", req.Method, req.URL.Path, req.Host)}
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.Index(req.URL.Path, http01Path) != 0 {
+		http.NotFound(w, req)
+		return
+	}
+	tok := strings.TrimPrefix(req.URL.Path, http01Path)
+
+	solver.mu.Lock()
+	keyAuth, ok := solver.toks[tok]
+	solver.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
+
+// function that validates a token, so that it can be used as a file name
+func checkHttp01Tok(tok string) (err error) {
+
+	if len(tok) == 0 {return fmt.Errorf("no token!")}
+	if strings.ContainsAny(tok, "/\\.") {return fmt.Errorf("invalid token: %s!", tok)}
+	return nil
+}
+
+// function that publishes the key authorization for a token
+// the key authorization is obtained with acme.Client.HTTP01ChallengeResponse
+func (solver *Http01Solver) Present(tok string, keyAuth string) (err error) {
+
+	err = checkHttp01Tok(tok)
+	if err != nil {return err}
+
+	if len(solver.Cfg.Webroot) > 0 {
+		chalDir := filepath.Join(solver.Cfg.Webroot, http01Path)
+		err = os.MkdirAll(chalDir, 0755)
+		if err != nil {return fmt.Errorf("os.MkdirAll: %v", err)}
+		err = os.WriteFile(filepath.Join(chalDir, tok), []byte(keyAuth), 0644)
+		if err != nil {return fmt.Errorf("os.WriteFile: %v", err)}
+		return nil
+	}
+
+	solver.mu.Lock()
+	solver.toks[tok] = keyAuth
+	solver.mu.Unlock()
+	return nil
+}
+
+func (solver *Http01Solver) CleanUp(tok string) (err error) {
+
+	err = checkHttp01Tok(tok)
+	if err != nil {return err}
+
+	if len(solver.Cfg.Webroot) > 0 {
+		err = os.Remove(filepath.Join(solver.Cfg.Webroot, http01Path, tok))
+		if err != nil && !os.IsNotExist(err) {return fmt.Errorf("os.Remove: %v", err)}
+		return nil
+	}
+
+	solver.mu.Lock()
+	delete(solver.toks, tok)
+	solver.mu.Unlock()
+	return nil
+}
+
+// function that fetches the key authorization from the domain
+// used to check that the challenge is reachable before the challenge is accepted; a failure is only a warning
+func CheckHttp01(domain string, tok string, keyAuth string) (err error) {
+
+	url := "http://" + domain + http01Path + tok
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {return fmt.Errorf("http get %s: %v", url, err)}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {return fmt.Errorf("http get %s: status %s!", url, resp.Status)}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {return fmt.Errorf("http get %s: %v", url, err)}
+	if strings.TrimSpace(string(body)) != keyAuth {return fmt.Errorf("http get %s: key authorization does not match!", url)}
+	return nil
+}
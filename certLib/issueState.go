@@ -0,0 +1,149 @@
+// issueState.go
+// persisted state machine of the issuance of createCertsV3
+// the state is saved in the state file of the csr list after each transition (see csrState.go), so that a run can resume where a crashed run stopped
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"time"
+)
+
+// states of the issuance in order
+const (
+	// no issuance in progress
+	IssueNew = ""
+	// the order was created; OrderUrl is set
+	IssueOrdered = "ordered"
+	// the challenges are being published; domains with ChalRecId are published
+	IssueAuthorizing = "authorizing"
+	// all challenges are published
+	IssueRecordsPresented = "records-presented"
+	// the challenges are visible on the name servers and the http-01 urls
+	IssuePropagated = "propagated"
+	// the CA was asked to validate all challenges
+	IssueChallengesAccepted = "challenges-accepted"
+	// the order is ready for the csr
+	IssueOrderReady = "order-ready"
+	// all certificates are issued; the certificate urls are set
+	IssueFinalized = "finalized"
+	// all certificates are saved
+	IssueDownloaded = "downloaded"
+	// the challenges are removed; the issuance is complete
+	IssueCleanedUp = "cleaned-up"
+)
+
+var issueStates = []string{IssueNew, IssueOrdered, IssueAuthorizing, IssueRecordsPresented, IssuePropagated,
+	IssueChallengesAccepted, IssueOrderReady, IssueFinalized, IssueDownloaded, IssueCleanedUp}
+
+// issuance state of a csr list
+type IssueState struct {
+	State string `yaml:"state"`
+	Updated time.Time `yaml:"updated"`
+	// account of the order and its index in the account list (account of the csr list followed by the fallback accounts)
+	Account string `yaml:"account"`
+	AcntIdx int `yaml:"acntIdx"`
+	// ARI id of the certificate replaced by the order
+	ReplacesId string `yaml:"replacesId"`
+	// certificates of the order; a dual csr list has two
+	Certs []IssueCert `yaml:"certs"`
+}
+
+// issuance state of one certificate
+type IssueCert struct {
+	CertNam string `yaml:"certName"`
+	KeyType string `yaml:"keyType"`
+	// the second certificate of a dual csr list has its own order
+	OrderUrl string `yaml:"orderUrl"`
+	// set when the order is finalized
+	CertUrl string `yaml:"certUrl"`
+	// new key of the csr; it replaces the key of the certificate once the certificate is saved; empty if the key is reused
+	NewKeyId string `yaml:"newKeyId"`
+	// true when the certificate and its meta file are saved
+	Saved bool `yaml:"saved"`
+}
+
+// function that returns the position of a state in the state order
+func IssueStateIdx(state string) (idx int, err error) {
+
+	for i, st := range issueStates {
+		if st == state {return i, nil}
+	}
+	return -1, fmt.Errorf("unknown issue state: %s!", state)
+}
+
+// function that tests whether the state of the csr list is at or after state
+func IssueStateReached(csrList *CsrList, state string) (ok bool) {
+
+	curIdx, err := IssueStateIdx(csrList.Issue.State)
+	if err != nil {return false}
+	idx, err := IssueStateIdx(state)
+	if err != nil {return false}
+	return curIdx >= idx
+}
+
+// function that sets the issue state of the csr list and saves the state of the csr list
+func SetIssueState(csrFilnam string, csrList *CsrList, state string) (err error) {
+
+	_, err = IssueStateIdx(state)
+	if err != nil {return err}
+
+	csrList.Issue.State = state
+	csrList.Issue.Updated = time.Now()
+	err = SaveCsrState(csrFilnam, csrList)
+	if err != nil {return fmt.Errorf("SaveCsrState: %v", err)}
+	return nil
+}
+
+// function that saves the state of the csr list without a state transition, e.g. after a certificate of the order is issued
+func SaveIssueState(csrFilnam string, csrList *CsrList) (err error) {
+	return SetIssueState(csrFilnam, csrList, csrList.Issue.State)
+}
+
+// function that checks that the state of the csr file is consistent
+func CheckIssueState(csrList *CsrList) (err error) {
+
+	_, err = IssueStateIdx(csrList.Issue.State)
+	if err != nil {return err}
+
+	if IssueStateReached(csrList, IssueOrdered) && !IssueStateReached(csrList, IssueCleanedUp) {
+		if len(csrList.OrderUrl) == 0 {return fmt.Errorf("state %s without order url!", csrList.Issue.State)}
+		if len(csrList.Issue.Account) == 0 {return fmt.Errorf("state %s without account!", csrList.Issue.State)}
+	}
+	if IssueStateReached(csrList, IssueRecordsPresented) && !IssueStateReached(csrList, IssueDownloaded) {
+		for i:=0; i< len(csrList.Domains); i++ {
+			if len(csrList.Domains[i].ChalRecId) == 0 {
+				return fmt.Errorf("state %s: domain %s has no challenge!", csrList.Issue.State, csrList.Domains[i].Domain)
+			}
+		}
+	}
+	return nil
+}
+
+// function that returns the certificate certNam of the order
+func GetIssueCert(csrList *CsrList, certNam string) (cert *IssueCert) {
+
+	for i:=0; i< len(csrList.Issue.Certs); i++ {
+		if csrList.Issue.Certs[i].CertNam == certNam {return &csrList.Issue.Certs[i]}
+	}
+	return nil
+}
+
+func PrintIssueState(csrList *CsrList) {
+
+	iss := csrList.Issue
+	fmt.Printf("*************** Issue State ***************\n")
+	fmt.Printf("state:    %s\n", iss.State)
+	fmt.Printf("updated:  %s\n", iss.Updated.Format(time.RFC1123))
+	fmt.Printf("account:  %s [%d]\n", iss.Account, iss.AcntIdx)
+	if len(iss.ReplacesId) > 0 {fmt.Printf("replaces: %s\n", iss.ReplacesId)}
+	for _, cert := range iss.Certs {
+		fmt.Printf("cert %s [%s] certUrl: %s saved: %t\n", cert.CertNam, cert.KeyType, cert.CertUrl, cert.Saved)
+		if len(cert.NewKeyId) > 0 {fmt.Printf("    new key: %s\n", cert.NewKeyId)}
+	}
+	fmt.Printf("************* End Issue State *****************\n")
+}
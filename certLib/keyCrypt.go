@@ -0,0 +1,182 @@
+// keyCrypt.go
+// at-rest encryption of the certificate and account keys
+// the environment variable keyEnc selects the encryption of new key files: none (default), pkcs8 or age
+// pkcs8: encrypted pkcs8 with the passphrase of keyPass or of the file keyPassFile
+// age: age file encrypted to the recipients of the file keyAgeRecipients; keyAgeIdentities names the identity file for reading
+// the key readers detect the format of a key file; plain key files remain readable
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/youmark/pkcs8"
+)
+
+const (
+	KeyEncNone = "none"
+	KeyEncPkcs8 = "pkcs8"
+	KeyEncAge = "age"
+)
+
+// pbkdf2 parameters of encrypted pkcs8 keys
+var pkcs8Opts = &pkcs8.Opts{
+	Cipher: pkcs8.AES256CBC,
+	KDFOpts: pkcs8.PBKDF2Opts{
+		SaltSize: 16,
+		IterationCount: 600000,
+		HMACHash: crypto.SHA256,
+	},
+}
+
+// function that returns the encryption of new key files
+func KeyEncMode() (mode string, err error) {
+
+	mode = os.Getenv("keyEnc")
+	switch mode {
+	case "", KeyEncNone:
+		return KeyEncNone, nil
+	case KeyEncPkcs8, KeyEncAge:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown key encryption keyEnc: %s!", mode)
+	}
+}
+
+// function that returns the passphrase of encrypted pkcs8 keys from keyPass or the file keyPassFile
+func keyPassphrase() (pass []byte, err error) {
+
+	if passStr := os.Getenv("keyPass"); len(passStr) > 0 {return []byte(passStr), nil}
+
+	passFilnam := os.Getenv("keyPassFile")
+	if len(passFilnam) == 0 {return nil, fmt.Errorf("no passphrase: neither keyPass nor keyPassFile is set!")}
+	passData, err := os.ReadFile(passFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+	pass = bytes.TrimRight(passData, "\r\n")
+	if len(pass) == 0 {return nil, fmt.Errorf("empty passphrase in %s!", passFilnam)}
+	return pass, nil
+}
+
+// function that reads the age recipients of the file keyAgeRecipients
+func keyAgeRecipients() (recipients []age.Recipient, err error) {
+
+	recFilnam := os.Getenv("keyAgeRecipients")
+	if len(recFilnam) == 0 {return nil, fmt.Errorf("keyAgeRecipients is not set!")}
+	recFil, err := os.Open(recFilnam)
+	if err != nil {return nil, fmt.Errorf("os.Open: %v", err)}
+	defer recFil.Close()
+
+	recipients, err = age.ParseRecipients(recFil)
+	if err != nil {return nil, fmt.Errorf("age.ParseRecipients: %v", err)}
+	return recipients, nil
+}
+
+// function that reads the age identities of the file keyAgeIdentities
+func keyAgeIdentities() (identities []age.Identity, err error) {
+
+	idFilnam := os.Getenv("keyAgeIdentities")
+	if len(idFilnam) == 0 {return nil, fmt.Errorf("keyAgeIdentities is not set!")}
+	idFil, err := os.Open(idFilnam)
+	if err != nil {return nil, fmt.Errorf("os.Open: %v", err)}
+	defer idFil.Close()
+
+	identities, err = age.ParseIdentities(idFil)
+	if err != nil {return nil, fmt.Errorf("age.ParseIdentities: %v", err)}
+	return identities, nil
+}
+
+// function that pem encodes a private key with the encryption selected by keyEnc
+// plainBlock is the unencrypted pem block of the key
+func encodeKeyPem(key crypto.Signer, plainBlock *pem.Block) (pemData []byte, err error) {
+
+	mode, err := KeyEncMode()
+	if err != nil {return nil, err}
+
+	switch mode {
+	case KeyEncPkcs8:
+		pass, err := keyPassphrase()
+		if err != nil {return nil, err}
+		encDer, err := pkcs8.MarshalPrivateKey(key, pass, pkcs8Opts)
+		if err != nil {return nil, fmt.Errorf("pkcs8.MarshalPrivateKey: %v", err)}
+		return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encDer}), nil
+
+	case KeyEncAge:
+		recipients, err := keyAgeRecipients()
+		if err != nil {return nil, err}
+		var buf bytes.Buffer
+		armorWriter := armor.NewWriter(&buf)
+		ageWriter, err := age.Encrypt(armorWriter, recipients...)
+		if err != nil {return nil, fmt.Errorf("age.Encrypt: %v", err)}
+		_, err = ageWriter.Write(pem.EncodeToMemory(plainBlock))
+		if err != nil {return nil, fmt.Errorf("age Write: %v", err)}
+		err = ageWriter.Close()
+		if err != nil {return nil, fmt.Errorf("age Close: %v", err)}
+		err = armorWriter.Close()
+		if err != nil {return nil, fmt.Errorf("armor Close: %v", err)}
+		return buf.Bytes(), nil
+
+	default:
+		return pem.EncodeToMemory(plainBlock), nil
+	}
+}
+
+// function that decodes the private key of a key file
+// age files and encrypted pkcs8 keys are decrypted; sec1, pkcs1 and pkcs8 keys are read as they are
+func decodeKeyPem(keyData []byte, keyFilnam string) (key crypto.Signer, err error) {
+
+	if strings.HasPrefix(strings.TrimSpace(string(keyData)), armor.Header) {
+		identities, err := keyAgeIdentities()
+		if err != nil {return nil, err}
+		ageReader, err := age.Decrypt(armor.NewReader(bytes.NewReader(keyData)), identities...)
+		if err != nil {return nil, fmt.Errorf("age.Decrypt %s: %v", keyFilnam, err)}
+		keyData, err = io.ReadAll(ageReader)
+		if err != nil {return nil, fmt.Errorf("age Read %s: %v", keyFilnam, err)}
+	}
+
+	block, _ := pem.Decode(keyData)
+	if block == nil {return nil, fmt.Errorf("no pem block in %s!", keyFilnam)}
+
+	var anyKey any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {return nil, fmt.Errorf("x509.ParseECPrivateKey: %v", err)}
+		return key, nil
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {return nil, fmt.Errorf("x509.ParsePKCS1PrivateKey: %v", err)}
+		return key, nil
+	case "PRIVATE KEY":
+		// the account keys of CreateLEAccount are sec1 keys in a PRIVATE KEY block
+		anyKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			ecKey, ecErr := x509.ParseECPrivateKey(block.Bytes)
+			if ecErr != nil {return nil, fmt.Errorf("x509.ParsePKCS8PrivateKey: %v", err)}
+			return ecKey, nil
+		}
+	case "ENCRYPTED PRIVATE KEY":
+		pass, err := keyPassphrase()
+		if err != nil {return nil, err}
+		anyKey, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, pass)
+		if err != nil {return nil, fmt.Errorf("pkcs8.ParsePKCS8PrivateKey %s: %v", keyFilnam, err)}
+	default:
+		return nil, fmt.Errorf("unknown pem block type %s!", block.Type)
+	}
+
+	key, ok := anyKey.(crypto.Signer)
+	if !ok {return nil, fmt.Errorf("key in %s is not a signing key!", keyFilnam)}
+	return key, nil
+}
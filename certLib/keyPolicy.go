@@ -0,0 +1,108 @@
+// keyPolicy.go
+// reuse policy of the certificate keys
+// with keypolicy reuse, a renewal signs the new csr with the existing key of the certificate, so that key pins and DANE TLSA records stay valid
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// a new key for each certificate (default)
+	KeyPolicyNew = "new"
+	// the existing key of the certificate; a new key if there is none
+	KeyPolicyReuse = "reuse"
+)
+
+// age in days after which a reused key triggers a warning
+const DefaultKeyMaxAge = 365
+
+// function that returns the key policy and the maximum key age in days of a domain
+func GetKeyPolicy(csrData CsrDat) (policy string, maxAge int) {
+
+	policy = csrData.KeyPolicy
+	if len(policy) == 0 {policy = KeyPolicyNew}
+	maxAge = csrData.KeyMaxAge
+	if maxAge == 0 {maxAge = DefaultKeyMaxAge}
+	return policy, maxAge
+}
+
+// function that checks the key policy of a domain
+func CheckKeyPolicy(csrData CsrDat) (err error) {
+
+	policy, maxAge := GetKeyPolicy(csrData)
+	if policy != KeyPolicyNew && policy != KeyPolicyReuse {return fmt.Errorf("unknown key policy: %s!", policy)}
+	if maxAge < 0 {return fmt.Errorf("invalid key max age: %d!", maxAge)}
+	return nil
+}
+
+// function that returns the key policy and the maximum key age of a certificate for all domains of the csr list
+// domains without key policy accept the key policy of the other domains
+func CsrKeyPolicy(csrList *CsrList) (policy string, maxAge int, err error) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		dom := csrList.Domains[i]
+		if len(dom.KeyPolicy) > 0 {
+			if len(policy) > 0 && policy != dom.KeyPolicy {
+				return "", 0, fmt.Errorf("domains have different key policies: %s and %s!", policy, dom.KeyPolicy)
+			}
+			policy = dom.KeyPolicy
+		}
+		if dom.KeyMaxAge > 0 {
+			if maxAge > 0 && maxAge != dom.KeyMaxAge {
+				return "", 0, fmt.Errorf("domains have different key max ages: %d and %d!", maxAge, dom.KeyMaxAge)
+			}
+			maxAge = dom.KeyMaxAge
+		}
+	}
+	csrData := CsrDat{KeyPolicy: policy, KeyMaxAge: maxAge}
+	err = CheckKeyPolicy(csrData)
+	if err != nil {return "", 0, err}
+	policy, maxAge = GetKeyPolicy(csrData)
+	return policy, maxAge, nil
+}
+
+// function that returns the key of a certificate according to the key policy
+// with policy reuse, the key keyId of the key store is loaded if it exists and has the key type keyType; otherwise the key store generates a new key newId
+// reused is true if the key was loaded; a new key replaces the key keyId only with CommitKey, after its certificate is saved
+// a reused key older than maxAge days triggers a warning
+func LoadCertKey(ks KeyStore, keyId string, newId string, keyType string, policy string, maxAge int) (key crypto.Signer, reused bool, err error) {
+
+	if policy == KeyPolicyReuse {
+		key, err = ks.LoadKey(keyId)
+		switch {
+		case err == nil:
+			oldType, err := KeyTypeOf(key)
+			if err != nil {return nil, false, fmt.Errorf("KeyTypeOf: %v", err)}
+			if oldType != keyType {
+				log.Printf("key %s has key type %s instead of %s -- generating a new key\n", keyId, oldType, keyType)
+				break
+			}
+			if ager, ok := ks.(keyAger); ok && maxAge > 0 {
+				age, err := ager.KeyAge(keyId)
+				if err != nil {return nil, false, fmt.Errorf("KeyAge: %v", err)}
+				if age > time.Duration(maxAge)*24*time.Hour {
+					log.Printf("warning: key %s is %d days old (max age %d days) -- consider a new key\n", keyId, int(age.Hours()/24), maxAge)
+				}
+			}
+			return key, true, nil
+		case errors.Is(err, ErrNoKey):
+			log.Printf("no key %s to reuse -- generating a new key\n", keyId)
+		default:
+			return nil, false, fmt.Errorf("LoadKey: %v", err)
+		}
+	}
+
+	key, err = ks.GenerateKey(newId, keyType)
+	if err != nil {return nil, false, fmt.Errorf("GenerateKey: %v", err)}
+	return key, false, nil
+}
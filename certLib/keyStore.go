@@ -0,0 +1,157 @@
+// keyStore.go
+// interface and registry for the key stores that hold the account and certificate keys
+// the file key store keeps the keys as pem files (see keyCrypt.go); the pkcs11 key store (build tag pkcs11) keeps them in a token
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultKeyStore = "file"
+
+// error of LoadKey for a key that is not in the key store
+var ErrNoKey = errors.New("key not found")
+
+// interface that each key store has to implement
+// keyId is the file name of the key for the file key store and the label of the key for the other key stores
+type KeyStore interface {
+	// name under which the key store is registered
+	Name() string
+	// returns the key keyId; ErrNoKey if the key store has no key keyId
+	LoadKey(keyId string) (key crypto.Signer, err error)
+	// generates a key of the key type and stores it as keyId; the file key store replaces an existing key file, other key stores refuse an existing key
+	GenerateKey(keyId string, keyType string) (key crypto.Signer, err error)
+	// makes the new key newId the key keyId of a certificate and returns the id of the key in use
+	// called once the certificate of the new key is saved; a second call after a crash does no harm
+	CommitKey(newId string, keyId string) (curId string, err error)
+}
+
+// optional interface of key stores that know the age of a key
+type keyAger interface {
+	KeyAge(keyId string) (age time.Duration, err error)
+}
+
+type KeyStoreFactory func() (ks KeyStore, err error)
+
+var keyStores = map[string]KeyStoreFactory{}
+
+func init() {
+	RegisterKeyStore(DefaultKeyStore, func() (KeyStore, error) {return fileKeyStore{}, nil})
+}
+
+// function that adds a key store to the registry
+func RegisterKeyStore(ksNam string, factory KeyStoreFactory) {
+	if len(ksNam) == 0 || factory == nil {return}
+	keyStores[strings.ToLower(ksNam)] = factory
+}
+
+// function that creates the key store registered under ksNam
+func NewKeyStore(ksNam string) (ks KeyStore, err error) {
+
+	if len(ksNam) == 0 {ksNam = DefaultKeyStore}
+
+	factory, ok := keyStores[strings.ToLower(ksNam)]
+	if !ok {return nil, fmt.Errorf("no key store registered with name: %s! available: %v", ksNam, KeyStoreNames())}
+
+	ks, err = factory()
+	if err != nil {return nil, fmt.Errorf("key store %s: %v", ksNam, err)}
+	return ks, nil
+}
+
+// function that returns the names of all registered key stores
+func KeyStoreNames() (names []string) {
+	for nam := range keyStores {
+		names = append(names, nam)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// function that tests whether ksNam selects the file key store
+func IsFileKeyStore(ksNam string) (ok bool) {
+	return len(ksNam) == 0 || strings.ToLower(ksNam) == DefaultKeyStore
+}
+
+// function that returns the key id of the key of certificate certNam
+// the label of a key in a key store other than file is recorded in the meta file of the certificate; older keys have the label certNam
+func CertKeyId(ksNam string, certDir string, certNam string) (keyId string) {
+
+	if IsFileKeyStore(ksNam) {return certDir + "/" + certNam + ".key"}
+	meta, err := ReadCertMeta(CertMetaFilnam(certDir, certNam))
+	if err == nil && len(meta.KeyId) > 0 {return meta.KeyId}
+	return certNam
+}
+
+// function that returns the key id under which a new key of certificate certNam is generated
+// the current key stays in use until the certificate of the new key is saved (see CommitKey)
+// a key of a key store other than file gets the new label <certNam>_<time>
+func NewCertKeyId(ksNam string, certDir string, certNam string) (newId string) {
+
+	if IsFileKeyStore(ksNam) {return CertKeyId(ksNam, certDir, certNam) + ".new"}
+	return certNam + "_" + time.Now().Format("20060102T150405")
+}
+
+// key store with the keys as pem files
+type fileKeyStore struct {}
+
+func (fileKeyStore) Name() string {return DefaultKeyStore}
+
+func (fileKeyStore) LoadKey(keyId string) (key crypto.Signer, err error) {
+
+	_, err = os.Stat(keyId)
+	if err != nil {
+		if os.IsNotExist(err) {return nil, ErrNoKey}
+		return nil, fmt.Errorf("os.Stat: %v", err)
+	}
+	return ReadKeyPem(keyId)
+}
+
+func (fileKeyStore) GenerateKey(keyId string, keyType string) (key crypto.Signer, err error) {
+
+	key, err = GenCertKey(keyType)
+	if err != nil {return nil, fmt.Errorf("GenCertKey: %v", err)}
+	err = SaveKeyPem(key, keyId)
+	if err != nil {return nil, fmt.Errorf("SaveKeyPem: %v", err)}
+	return key, nil
+}
+
+// the new key file replaces the key file; a missing new key file was moved by an earlier run
+func (fileKeyStore) CommitKey(newId string, keyId string) (curId string, err error) {
+
+	if newId == keyId {return keyId, nil}
+	err = os.Rename(newId, keyId)
+	if err != nil {
+		if os.IsNotExist(err) {
+			_, statErr := os.Stat(keyId)
+			if statErr == nil {return keyId, nil}
+		}
+		return "", fmt.Errorf("os.Rename: %v", err)
+	}
+	return keyId, nil
+}
+
+// the key file is not rewritten while the key is reused; its modification time is the age of the key
+func (fileKeyStore) KeyAge(keyId string) (age time.Duration, err error) {
+
+	info, err := os.Stat(keyId)
+	if err != nil {return 0, fmt.Errorf("os.Stat: %v", err)}
+	return time.Since(info.ModTime()), nil
+}
+
+// function that returns the key id of the account key in a key store other than file
+func AcntKeyId(le *LEObj) (keyId string) {
+
+	if len(le.KeyId) > 0 {return le.KeyId}
+	return le.AcntNam
+}
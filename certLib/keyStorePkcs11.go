@@ -0,0 +1,136 @@
+//go:build pkcs11
+
+// keyStorePkcs11.go
+// implementation of the KeyStore interface with a PKCS#11 token (hsm, SoftHSM)
+// the key id is the label of the key pair in the token
+// build with: go build -tags pkcs11 (requires cgo)
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ThalesIgnite/crypto11"
+	yaml "github.com/goccy/go-yaml"
+)
+
+// content of the yaml file pkcs11.yaml in the LEAcnt directory
+type Pkcs11Cfg struct {
+	// path of the pkcs11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Module string `yaml:"module"`
+	TokenLabel string `yaml:"tokenLabel"`
+	// user pin; the environmental variable pkcs11Pin or the file pinFile take precedence
+	Pin string `yaml:"pin"`
+	PinFile string `yaml:"pinFile"`
+}
+
+type pkcs11KeyStore struct {
+	ctx *crypto11.Context
+}
+
+func init() {
+	RegisterKeyStore("pkcs11", newPkcs11KeyStoreFromFil)
+}
+
+// function that returns the name of the pkcs11 configuration file
+func Pkcs11Filnam() (filnam string, err error) {
+
+	LEDir, err := GetCertDir("LEAcnt")
+	if err != nil {return "", fmt.Errorf("GetCertDir: %v", err)}
+	return LEDir + "pkcs11.yaml", nil
+}
+
+func newPkcs11KeyStoreFromFil() (ks KeyStore, err error) {
+
+	cfgFilnam, err := Pkcs11Filnam()
+	if err != nil {return nil, fmt.Errorf("Pkcs11Filnam: %v", err)}
+
+	bytData, err := os.ReadFile(cfgFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	cfg := &Pkcs11Cfg{}
+	err = yaml.Unmarshal(bytData, cfg)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+
+	return NewPkcs11KeyStore(cfg)
+}
+
+// function that opens the token of the pkcs11 configuration
+func NewPkcs11KeyStore(cfg *Pkcs11Cfg) (ks KeyStore, err error) {
+
+	if len(cfg.Module) == 0 {return nil, fmt.Errorf("no pkcs11 module!")}
+	if len(cfg.TokenLabel) == 0 {return nil, fmt.Errorf("no token label!")}
+
+	pin := cfg.Pin
+	if len(cfg.PinFile) > 0 {
+		pinData, err := os.ReadFile(cfg.PinFile)
+		if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+		pin = strings.TrimSpace(string(pinData))
+	}
+	if envPin := os.Getenv("pkcs11Pin"); len(envPin) > 0 {pin = envPin}
+	if len(pin) == 0 {return nil, fmt.Errorf("no pin for token %s!", cfg.TokenLabel)}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path: cfg.Module,
+		TokenLabel: cfg.TokenLabel,
+		Pin: pin,
+	})
+	if err != nil {return nil, fmt.Errorf("crypto11.Configure: %v", err)}
+	return &pkcs11KeyStore{ctx: ctx}, nil
+}
+
+func (ks *pkcs11KeyStore) Name() string {return "pkcs11"}
+
+func (ks *pkcs11KeyStore) LoadKey(keyId string) (key crypto.Signer, err error) {
+
+	signer, err := ks.ctx.FindKeyPair(nil, []byte(keyId))
+	if err != nil {return nil, fmt.Errorf("FindKeyPair: %v", err)}
+	if signer == nil {return nil, ErrNoKey}
+	return signer, nil
+}
+
+// a token cannot relabel a key pair: the new key keeps its label and the old key pair keyId is deleted
+// the caller records the label of the new key (meta file of the certificate)
+func (ks *pkcs11KeyStore) CommitKey(newId string, keyId string) (curId string, err error) {
+
+	if newId == keyId {return keyId, nil}
+	old, err := ks.ctx.FindKeyPair(nil, []byte(keyId))
+	if err != nil {return "", fmt.Errorf("FindKeyPair: %v", err)}
+	if old != nil {
+		err = old.Delete()
+		if err != nil {return "", fmt.Errorf("Delete %s: %v", keyId, err)}
+	}
+	return newId, nil
+}
+
+// an existing key pair is never replaced; a label identifies one key pair
+func (ks *pkcs11KeyStore) GenerateKey(keyId string, keyType string) (key crypto.Signer, err error) {
+
+	old, err := ks.ctx.FindKeyPair(nil, []byte(keyId))
+	if err != nil {return nil, fmt.Errorf("FindKeyPair: %v", err)}
+	if old != nil {return nil, fmt.Errorf("key pair %s exists!", keyId)}
+
+	id := []byte(keyId)
+	switch keyType {
+	case KeyEc256:
+		return ks.ctx.GenerateECDSAKeyPairWithLabel(id, id, elliptic.P256())
+	case KeyEc384:
+		return ks.ctx.GenerateECDSAKeyPairWithLabel(id, id, elliptic.P384())
+	case KeyRsa2048:
+		return ks.ctx.GenerateRSAKeyPairWithLabel(id, id, 2048)
+	case KeyRsa3072:
+		return ks.ctx.GenerateRSAKeyPairWithLabel(id, id, 3072)
+	case KeyRsa4096:
+		return ks.ctx.GenerateRSAKeyPairWithLabel(id, id, 4096)
+	default:
+		return nil, fmt.Errorf("key type %s is not supported by the pkcs11 key store!", keyType)
+	}
+}
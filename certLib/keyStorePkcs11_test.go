@@ -0,0 +1,146 @@
+//go:build pkcs11
+
+// keyStorePkcs11_test.go
+// tests of the pkcs11 key store against a SoftHSM token
+// the test is skipped if SOFTHSM2_CONF is not set
+// the module path, the token label and the pin are read from pkcs11Module, pkcs11Token and pkcs11Pin
+// run with: go test -tags pkcs11 -run Pkcs11
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// function that opens the SoftHSM token of the test
+func testPkcs11KeyStore(t *testing.T) (ks KeyStore) {
+
+	t.Helper()
+	if len(os.Getenv("SOFTHSM2_CONF")) == 0 {t.Skip("SOFTHSM2_CONF is not set")}
+
+	cfg := &Pkcs11Cfg{
+		Module: os.Getenv("pkcs11Module"),
+		TokenLabel: os.Getenv("pkcs11Token"),
+		Pin: "1234",
+	}
+	if len(cfg.Module) == 0 {cfg.Module = "/usr/lib/softhsm/libsofthsm2.so"}
+	if len(cfg.TokenLabel) == 0 {cfg.TokenLabel = "acme-test"}
+
+	ks, err := NewPkcs11KeyStore(cfg)
+	if err != nil {t.Fatalf("NewPkcs11KeyStore: %v", err)}
+	return ks
+}
+
+// function that removes the key pair keyId of the token at the end of the test
+func cleanTestPkcs11Key(t *testing.T, ks KeyStore, keyId string) {
+
+	t.Cleanup(func() {
+		p11 := ks.(*pkcs11KeyStore)
+		key, err := p11.ctx.FindKeyPair(nil, []byte(keyId))
+		if err == nil && key != nil {key.Delete()}
+	})
+}
+
+// function that signs a digest with key and verifies the signature with its public key
+func checkTestSign(t *testing.T, key crypto.Signer) {
+
+	t.Helper()
+	digest := sha256.Sum256([]byte("acme test message"))
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {t.Fatalf("Sign: %v", err)}
+
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {t.Fatalf("ecdsa signature does not verify")}
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+		if err != nil {t.Fatalf("rsa signature does not verify: %v", err)}
+	default:
+		t.Fatalf("public key type %T", pub)
+	}
+}
+
+func TestPkcs11GenerateKey(t *testing.T) {
+
+	ks := testPkcs11KeyStore(t)
+	if ks.Name() != "pkcs11" {t.Fatalf("Name: got %s", ks.Name())}
+
+	for _, keyType := range []string{KeyEc256, KeyEc384, KeyRsa2048} {
+		keyId := fmt.Sprintf("test-%s-%d", keyType, time.Now().UnixNano())
+		cleanTestPkcs11Key(t, ks, keyId)
+
+		key, err := ks.GenerateKey(keyId, keyType)
+		if err != nil {t.Fatalf("GenerateKey %s: %v", keyType, err)}
+		genType, err := KeyTypeOf(key)
+		if err != nil || genType != keyType {t.Fatalf("KeyTypeOf: got %s %v, want %s", genType, err, keyType)}
+		checkTestSign(t, key)
+
+		// an existing key pair is not replaced
+		_, err = ks.GenerateKey(keyId, keyType)
+		if err == nil {t.Fatalf("GenerateKey %s twice: no error", keyId)}
+	}
+
+	_, err := ks.GenerateKey(fmt.Sprintf("test-ed-%d", time.Now().UnixNano()), KeyEd25519)
+	if err == nil {t.Fatalf("GenerateKey ed25519: no error")}
+}
+
+func TestPkcs11LoadKey(t *testing.T) {
+
+	ks := testPkcs11KeyStore(t)
+	keyId := fmt.Sprintf("test-load-%d", time.Now().UnixNano())
+	cleanTestPkcs11Key(t, ks, keyId)
+
+	_, err := ks.LoadKey(keyId)
+	if !errors.Is(err, ErrNoKey) {t.Fatalf("LoadKey missing key: got %v, want ErrNoKey", err)}
+
+	genKey, err := ks.GenerateKey(keyId, KeyEc256)
+	if err != nil {t.Fatalf("GenerateKey: %v", err)}
+
+	key, err := ks.LoadKey(keyId)
+	if err != nil {t.Fatalf("LoadKey: %v", err)}
+	if !key.Public().(*ecdsa.PublicKey).Equal(genKey.Public()) {t.Fatalf("LoadKey: public key differs from the generated key")}
+	checkTestSign(t, key)
+}
+
+func TestPkcs11CommitKey(t *testing.T) {
+
+	ks := testPkcs11KeyStore(t)
+	keyId := fmt.Sprintf("test-cert-%d", time.Now().UnixNano())
+	newId := keyId + "_new"
+	cleanTestPkcs11Key(t, ks, keyId)
+	cleanTestPkcs11Key(t, ks, newId)
+
+	_, err := ks.GenerateKey(keyId, KeyEc256)
+	if err != nil {t.Fatalf("GenerateKey %s: %v", keyId, err)}
+	_, err = ks.GenerateKey(newId, KeyEc256)
+	if err != nil {t.Fatalf("GenerateKey %s: %v", newId, err)}
+
+	// the old key stays in the token until the new key is committed
+	_, err = ks.LoadKey(keyId)
+	if err != nil {t.Fatalf("LoadKey %s before CommitKey: %v", keyId, err)}
+
+	curId, err := ks.CommitKey(newId, keyId)
+	if err != nil || curId != newId {t.Fatalf("CommitKey: got %s %v, want %s", curId, err, newId)}
+	_, err = ks.LoadKey(keyId)
+	if !errors.Is(err, ErrNoKey) {t.Fatalf("LoadKey %s after CommitKey: got %v, want ErrNoKey", keyId, err)}
+	key, err := ks.LoadKey(newId)
+	if err != nil {t.Fatalf("LoadKey %s after CommitKey: %v", newId, err)}
+	checkTestSign(t, key)
+
+	// a second call after a crash does no harm
+	curId, err = ks.CommitKey(newId, keyId)
+	if err != nil || curId != newId {t.Fatalf("CommitKey twice: got %s %v", curId, err)}
+}
@@ -0,0 +1,176 @@
+// keyType.go
+// key algorithms of the certificate keys
+// each domain of a csr list selects its key type with keytype; the default is ec256
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+const (
+	KeyRsa2048 = "rsa2048"
+	KeyRsa3072 = "rsa3072"
+	KeyRsa4096 = "rsa4096"
+	KeyEc256 = "ec256"
+	KeyEc384 = "ec384"
+	// not accepted by Let's Encrypt; for internal CAs
+	KeyEd25519 = "ed25519"
+)
+
+const DefaultKeyType = KeyEc256
+
+// key types of the two certificates of a dual csr list
+// the certificates are saved as <name>.ecdsa.crt and <name>.rsa.crt
+type DualCfg struct {
+	// ec256 (default) or ec384
+	Ecdsa string `yaml:"ecdsa"`
+	// rsa2048 (default), rsa3072 or rsa4096
+	Rsa string `yaml:"rsa"`
+}
+
+// function that returns the key type of a domain
+func GetKeyType(csrData CsrDat) (keyType string) {
+	if len(csrData.KeyType) == 0 {return DefaultKeyType}
+	return csrData.KeyType
+}
+
+// function that checks the key type of each domain of the csr list
+func CheckKeyTypes(csrList *CsrList) (err error) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		keyType := GetKeyType(csrList.Domains[i])
+		_, err = SigAlgo(keyType)
+		if err != nil {return fmt.Errorf("domain %s: %v", csrList.Domains[i].Domain, err)}
+	}
+	return nil
+}
+
+// function that returns the key type of a certificate for all domains of the csr list
+// domains without key type accept the key type of the other domains
+func CsrKeyType(csrList *CsrList) (keyType string, err error) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		domKeyType := csrList.Domains[i].KeyType
+		if len(domKeyType) == 0 {continue}
+		if len(keyType) > 0 && keyType != domKeyType {
+			return "", fmt.Errorf("domains have different key types: %s and %s!", keyType, domKeyType)
+		}
+		keyType = domKeyType
+	}
+	if len(keyType) == 0 {keyType = DefaultKeyType}
+	_, err = SigAlgo(keyType)
+	if err != nil {return "", err}
+	return keyType, nil
+}
+
+// function that returns the key types of the ecdsa and the rsa certificate of a dual csr list
+func DualKeyTypes(dual *DualCfg) (ecType string, rsaType string, err error) {
+
+	ecType = KeyEc256
+	if len(dual.Ecdsa) > 0 {ecType = dual.Ecdsa}
+	if ecType != KeyEc256 && ecType != KeyEc384 {return "", "", fmt.Errorf("dual ecdsa: %s is not an ecdsa key type!", ecType)}
+
+	rsaType = KeyRsa2048
+	if len(dual.Rsa) > 0 {rsaType = dual.Rsa}
+	if rsaType != KeyRsa2048 && rsaType != KeyRsa3072 && rsaType != KeyRsa4096 {
+		return "", "", fmt.Errorf("dual rsa: %s is not an rsa key type!", rsaType)
+	}
+	return ecType, rsaType, nil
+}
+
+// function that returns the name of a certificate of a dual csr list: <certNam>.ecdsa or <certNam>.rsa
+func DualCertNam(certNam string, keyType string) (dualNam string) {
+
+	switch keyType {
+	case KeyRsa2048, KeyRsa3072, KeyRsa4096:
+		return certNam + ".rsa"
+	default:
+		return certNam + ".ecdsa"
+	}
+}
+
+// function that returns the signature algorithm of the csr for a key type
+func SigAlgo(keyType string) (algo x509.SignatureAlgorithm, err error) {
+
+	switch keyType {
+	case KeyRsa2048, KeyRsa3072, KeyRsa4096:
+		return x509.SHA256WithRSA, nil
+	case KeyEc256:
+		return x509.ECDSAWithSHA256, nil
+	case KeyEc384:
+		return x509.ECDSAWithSHA384, nil
+	case KeyEd25519:
+		return x509.PureEd25519, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unknown key type: %s!", keyType)
+	}
+}
+
+// function that returns the key type of a key
+func KeyTypeOf(key crypto.Signer) (keyType string, err error) {
+	return PubKeyType(key.Public())
+}
+
+// function that returns the key type of a public key, e.g. of a certificate
+func PubKeyType(pubKey crypto.PublicKey) (keyType string, err error) {
+
+	switch pub := pubKey.(type) {
+	case *rsa.PublicKey:
+		switch pub.N.BitLen() {
+		case 2048:
+			return KeyRsa2048, nil
+		case 3072:
+			return KeyRsa3072, nil
+		case 4096:
+			return KeyRsa4096, nil
+		}
+		return "", fmt.Errorf("unsupported rsa key size: %d!", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return KeyEc256, nil
+		case elliptic.P384():
+			return KeyEc384, nil
+		}
+		return "", fmt.Errorf("unsupported ecdsa curve: %s!", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return KeyEd25519, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T!", pub)
+	}
+}
+
+// function that generates a private key of the key type
+func genKey(keyType string) (key crypto.Signer, err error) {
+
+	switch keyType {
+	case KeyRsa2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyRsa3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case KeyRsa4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyEc256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyEc384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyEd25519:
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {return nil, err}
+		return edKey, nil
+	default:
+		return nil, fmt.Errorf("unknown key type: %s!", keyType)
+	}
+}
@@ -0,0 +1,238 @@
+// renew.go
+// functions that schedule the renewal of the certificates in the cert directory
+// the schedule is saved in a yaml file, so that it survives restarts of the renewal daemon
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+)
+
+// content of the file renew.yaml
+// a certificate is renewed Days before expiry or when Fraction of its lifetime has passed, whichever is earlier
+type RenewCfg struct {
+	Days int `yaml:"days"`
+	Fraction float64 `yaml:"fraction"`
+	// random delay added to the start of the renewal window in hours; default 12
+	Jitter int `yaml:"jitter"`
+	// minutes between scans of the cert directory; default 60
+	ScanInterval int `yaml:"scanInterval"`
+	// maximum wait between attempts after a failed renewal in hours; default 24
+	MaxRetryWait int `yaml:"maxRetryWait"`
+	// program that renews a certificate; it is called with /csr=<csr file>; default ./createCertsV3
+	Cmd string `yaml:"cmd"`
+}
+
+type RenewEntry struct {
+	CertNam string `yaml:"certName"`
+	CsrFil string `yaml:"csrFile"`
+	// renewal program of the meta file
+	Cmd string `yaml:"cmd"`
+	NotAfter time.Time `yaml:"notAfter"`
+	RenewAt time.Time `yaml:"renewAt"`
+	// ARI window used for RenewAt
+	AriStart time.Time `yaml:"ariStart"`
+	AriEnd time.Time `yaml:"ariEnd"`
+	LastTry time.Time `yaml:"lastTry"`
+	LastErr string `yaml:"lastErr"`
+	Tries int `yaml:"tries"`
+}
+
+// content of the file schedule.yaml
+type RenewSched struct {
+	Updated time.Time `yaml:"updated"`
+	Entries []RenewEntry `yaml:"entries"`
+}
+
+// function that reads the renewal configuration; a missing file results in the defaults
+func ReadRenewCfg(cfgFilnam string) (cfg *RenewCfg, err error) {
+
+	cfg = &RenewCfg{}
+	bytData, err := os.ReadFile(cfgFilnam)
+	if err != nil && !os.IsNotExist(err) {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+	if err == nil {
+		err = yaml.Unmarshal(bytData, cfg)
+		if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+	}
+
+	if cfg.Days == 0 && cfg.Fraction == 0 {cfg.Days = 30}
+	if cfg.Fraction < 0 || cfg.Fraction >= 1 {return nil, fmt.Errorf("fraction %f is not in [0, 1)!", cfg.Fraction)}
+	if cfg.Jitter == 0 {cfg.Jitter = 12}
+	if cfg.ScanInterval == 0 {cfg.ScanInterval = 60}
+	if cfg.MaxRetryWait == 0 {cfg.MaxRetryWait = 24}
+	if len(cfg.Cmd) == 0 {cfg.Cmd = "./createCertsV3"}
+	return cfg, nil
+}
+
+// function that reads the schedule; a missing file is an empty schedule
+func ReadRenewSched(schedFilnam string) (sched *RenewSched, err error) {
+
+	sched = &RenewSched{}
+	bytData, err := os.ReadFile(schedFilnam)
+	if err != nil {
+		if os.IsNotExist(err) {return sched, nil}
+		return nil, fmt.Errorf("os.ReadFile: %v", err)
+	}
+
+	err = yaml.Unmarshal(bytData, sched)
+	if err != nil {return nil, fmt.Errorf("yaml Unmarshal: %v", err)}
+	return sched, nil
+}
+
+// the schedule is written to a temporary file and renamed
+func WriteRenewSched(schedFilnam string, sched *RenewSched) (err error) {
+
+	sched.Updated = time.Now()
+	schedByt, err := yaml.Marshal(sched)
+	if err != nil {return fmt.Errorf("yaml Marshal: %v", err)}
+
+	err = os.MkdirAll(filepath.Dir(schedFilnam), 0700)
+	if err != nil {return fmt.Errorf("os.MkdirAll: %v", err)}
+
+	err = writeFileAtomic(schedFilnam, schedByt, 0600)
+	if err != nil {return fmt.Errorf("writeFileAtomic: %v", err)}
+	return nil
+}
+
+// function that returns the start of the renewal window of a certificate
+func RenewWindow(notBefore time.Time, notAfter time.Time, cfg *RenewCfg) (start time.Time) {
+
+	start = notAfter
+	if cfg.Days > 0 {
+		start = notAfter.Add(-time.Duration(cfg.Days) * 24 * time.Hour)
+	}
+	if cfg.Fraction > 0 {
+		lifetime := notAfter.Sub(notBefore)
+		fracStart := notBefore.Add(time.Duration(float64(lifetime) * cfg.Fraction))
+		if fracStart.Before(start) {start = fracStart}
+	}
+	return start
+}
+
+// function that adds the certificates of the cert directory to the schedule
+// an entry keeps its renewal time as long as the certificate is unchanged
+// certificates without meta file cannot be renewed and are returned in skipped
+// the renewal window suggested by the CA (ARI) takes precedence over the configured window
+// revoked certificates are not added
+func UpdateRenewSched(sched *RenewSched, certDir string, cfg *RenewCfg) (skipped []string, err error) {
+
+	certNames, err := ListCertNames(certDir)
+	if err != nil {return nil, fmt.Errorf("ListCertNames: %v", err)}
+
+	oldEntries := make(map[string]RenewEntry)
+	for _, entry := range sched.Entries {
+		oldEntries[entry.CertNam] = entry
+	}
+
+	entries := []RenewEntry{}
+	for _, certNam := range certNames {
+		meta, err := ReadCertMeta(CertMetaFilnam(certDir, certNam))
+		if err != nil {
+			skipped = append(skipped, certNam)
+			continue
+		}
+
+		leaf, err := ReadLeafCert(certDir + "/" + certNam + ".crt")
+		if err != nil {return nil, fmt.Errorf("ReadLeafCert %s: %v", certNam, err)}
+		if IsRevoked(meta, leaf) {continue}
+
+		// the ari window of the meta file belongs to the current certificate
+		hasAri := !meta.AriEnd.IsZero() && meta.NotAfter.Equal(leaf.NotAfter)
+
+		entry, ok := oldEntries[certNam]
+		if ok && entry.NotAfter.Equal(leaf.NotAfter) {
+			entry.CsrFil = meta.CsrFil
+			entry.Cmd = meta.Cmd
+			// a new window suggested by the CA replaces the renewal time, unless a retry is pending
+			if hasAri && entry.Tries == 0 && !(entry.AriStart.Equal(meta.AriStart) && entry.AriEnd.Equal(meta.AriEnd)) {
+				entry.AriStart = meta.AriStart
+				entry.AriEnd = meta.AriEnd
+				entry.RenewAt = AriRenewAt(meta.AriStart, meta.AriEnd, time.Now())
+			}
+			entries = append(entries, entry)
+			continue
+		}
+
+		// new or renewed certificate
+		jitter := time.Duration(0)
+		if cfg.Jitter > 0 {jitter = time.Duration(rand.Int63n(int64(cfg.Jitter) * int64(time.Hour)))}
+		entry = RenewEntry{
+			CertNam: certNam,
+			CsrFil: meta.CsrFil,
+			Cmd: meta.Cmd,
+			NotAfter: leaf.NotAfter,
+			RenewAt: RenewWindow(leaf.NotBefore, leaf.NotAfter, cfg).Add(jitter),
+		}
+		// the renewal has to be done before expiry
+		if !entry.RenewAt.Before(entry.NotAfter) {entry.RenewAt = RenewWindow(leaf.NotBefore, leaf.NotAfter, cfg)}
+		if hasAri {
+			entry.AriStart = meta.AriStart
+			entry.AriEnd = meta.AriEnd
+			entry.RenewAt = AriRenewAt(meta.AriStart, meta.AriEnd, time.Now())
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {return entries[i].RenewAt.Before(entries[j].RenewAt)})
+	sched.Entries = entries
+	return skipped, nil
+}
+
+// function that returns the indices of the entries that are due for renewal
+func DueRenewals(sched *RenewSched, now time.Time) (idxList []int) {
+
+	for i:=0; i< len(sched.Entries); i++ {
+		if !now.Before(sched.Entries[i].RenewAt) {idxList = append(idxList, i)}
+	}
+	return idxList
+}
+
+// function that records the outcome of a renewal attempt
+// after a failure the next attempt is delayed by 1h, 2h, 4h ... up to MaxRetryWait
+func SetRenewResult(entry *RenewEntry, renewErr error, cfg *RenewCfg, now time.Time) {
+
+	entry.LastTry = now
+	if renewErr == nil {
+		entry.LastErr = ""
+		entry.Tries = 0
+		return
+	}
+
+	entry.LastErr = renewErr.Error()
+	entry.Tries++
+	wait := time.Hour
+	maxWait := time.Duration(cfg.MaxRetryWait) * time.Hour
+	for i:=1; i< entry.Tries && wait < maxWait; i++ {
+		wait *= 2
+	}
+	if wait > maxWait {wait = maxWait}
+	entry.RenewAt = now.Add(wait)
+}
+
+func PrintRenewSched(sched *RenewSched) {
+
+	fmt.Printf("*************** Renewal Schedule: %d ***************\n", len(sched.Entries))
+	for i, entry := range sched.Entries {
+		fmt.Printf("cert[%d]: %s csr: %s\n", i+1, entry.CertNam, entry.CsrFil)
+		fmt.Printf("    not after: %s\n", entry.NotAfter.Format(time.RFC1123))
+		fmt.Printf("    renew at:  %s\n", entry.RenewAt.Format(time.RFC1123))
+		if !entry.AriEnd.IsZero() {
+			fmt.Printf("    ari window: %s - %s\n", entry.AriStart.Format(time.RFC1123), entry.AriEnd.Format(time.RFC1123))
+		}
+		if entry.Tries > 0 {
+			fmt.Printf("    tries: %d last: %s err: %s\n", entry.Tries, entry.LastTry.Format(time.RFC1123), entry.LastErr)
+		}
+	}
+	fmt.Printf("************* End Renewal Schedule *****************\n")
+}
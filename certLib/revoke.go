@@ -0,0 +1,106 @@
+// revoke.go
+// functions that revoke a certificate of the cert directory
+// the revocation is recorded in the meta file, so that the renewal daemon does not renew the certificate
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// reason codes of RFC 5280 section 5.3.1 accepted by the acme CAs
+var RevokeReasons = map[string]acme.CRLReasonCode {
+	"unspecified": acme.CRLReasonUnspecified,
+	"keyCompromise": acme.CRLReasonKeyCompromise,
+	"affiliationChanged": acme.CRLReasonAffiliationChanged,
+	"superseded": acme.CRLReasonSuperseded,
+	"cessationOfOperation": acme.CRLReasonCessationOfOperation,
+}
+
+// function that converts a reason name or number into a reason code
+func ParseRevokeReason(reasonStr string) (reason acme.CRLReasonCode, err error) {
+
+	reason, ok := RevokeReasons[reasonStr]
+	if ok {return reason, nil}
+
+	num, err := strconv.Atoi(reasonStr)
+	if err != nil {return 0, fmt.Errorf("unknown reason %s!", reasonStr)}
+	for _, code := range RevokeReasons {
+		if int(code) == num {return code, nil}
+	}
+	return 0, fmt.Errorf("reason code %d is not accepted!", num)
+}
+
+// function that returns the name of a reason code
+func RevokeReasonName(reason acme.CRLReasonCode) (nam string) {
+
+	for key, code := range RevokeReasons {
+		if code == reason {return key}
+	}
+	return strconv.Itoa(int(reason))
+}
+
+// function that reads a pem encoded private key (sec1, pkcs1 or pkcs8)
+// encrypted keys are decrypted, see keyCrypt.go
+func ReadKeyPem(keyFilnam string) (key crypto.Signer, err error) {
+
+	keyData, err := os.ReadFile(keyFilnam)
+	if err != nil {return nil, fmt.Errorf("os.ReadFile: %v", err)}
+
+	return decodeKeyPem(keyData, keyFilnam)
+}
+
+// function that revokes the certificate certNam of the cert directory
+// if useCertKey is true, the request is signed with the private key of the certificate (<certNam>.key),
+// otherwise with the account key of the client
+// the revocation is recorded in the meta file of the certificate
+func RevokeCertFil(ctx context.Context, client *acme.Client, certDir string, certNam string, useCertKey bool, reason acme.CRLReasonCode) (err error) {
+
+	leaf, err := ReadLeafCert(certDir + "/" + certNam + ".crt")
+	if err != nil {return fmt.Errorf("ReadLeafCert: %v", err)}
+
+	// a nil key selects the account key
+	var key crypto.Signer
+	if useCertKey {
+		key, err = ReadKeyPem(certDir + "/" + certNam + ".key")
+		if err != nil {return fmt.Errorf("ReadKeyPem: %v", err)}
+	}
+
+	err = client.RevokeCert(ctx, key, leaf.Raw, reason)
+	if err != nil {return fmt.Errorf("client.RevokeCert: %v", err)}
+
+	// certificates issued before the meta files were introduced get a minimal meta file
+	metaFilnam := CertMetaFilnam(certDir, certNam)
+	meta, err := ReadCertMeta(metaFilnam)
+	if err != nil {
+		meta = &CertMeta{
+			CertNam: certNam,
+			Domains: leaf.DNSNames,
+			NotBefore: leaf.NotBefore,
+			NotAfter: leaf.NotAfter,
+		}
+	}
+	meta.Revoked = time.Now()
+	meta.RevokeReason = RevokeReasonName(reason)
+
+	err = WriteCertMeta(metaFilnam, meta)
+	if err != nil {return fmt.Errorf("certificate revoked but WriteCertMeta: %v", err)}
+	return nil
+}
+
+// function that tests whether the current certificate of the meta file was revoked
+func IsRevoked(meta *CertMeta, leaf *x509.Certificate) (ok bool) {
+	return !meta.Revoked.IsZero() && meta.NotAfter.Equal(leaf.NotAfter)
+}
@@ -0,0 +1,182 @@
+// rollover.go
+// functions that replace the key of a CA account (acme key change, RFC 8555 section 7.3.5)
+// the previous key is kept in the archive directory of the account folder for a set period
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// default number of days an archived account key is kept
+const DefaultKeyArchiveDays = 30
+
+// function that pem encodes an account key in the format of CreateLEAccount
+func encodeAcntKey(privateKey *ecdsa.PrivateKey) (privPem []byte, pubPem []byte, err error) {
+
+	x509Encoded, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {return nil, nil, fmt.Errorf("x509.MarshalECPrivateKey: %v", err)}
+	privPem, err = encodeKeyPem(privateKey, &pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
+	if err != nil {return nil, nil, fmt.Errorf("encodeKeyPem: %v", err)}
+
+	x509EncodedPub, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {return nil, nil, fmt.Errorf("x509.MarshalPKIXPublicKey: %v", err)}
+	pubPem = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509EncodedPub})
+	return privPem, pubPem, nil
+}
+
+// function that returns the archive directory of the account keys
+func KeyArchiveDir(le *LEObj) (archDir string) {
+	return filepath.Join(filepath.Dir(le.PrivKeyFilnam), "archive")
+}
+
+// function that copies a key file into the archive directory
+// the archived file name contains the time of the rollover
+func archiveKeyFil(keyFilnam string, archDir string, now time.Time) (archFilnam string, err error) {
+
+	keyData, err := os.ReadFile(keyFilnam)
+	if err != nil {return "", fmt.Errorf("os.ReadFile: %v", err)}
+
+	base := filepath.Base(keyFilnam)
+	ext := filepath.Ext(base)
+	archFilnam = filepath.Join(archDir, strings.TrimSuffix(base, ext) + "_" + now.Format("20060102T150405") + ext)
+
+	err = os.WriteFile(archFilnam, keyData, 0600)
+	if err != nil {return "", fmt.Errorf("os.WriteFile: %v", err)}
+	return archFilnam, nil
+}
+
+// function that replaces the key of the account acntNam
+// the new key is registered with the CA; then the old key files are archived and the new key files replace them
+func RolloverLEAcnt(acntNam string, dbg bool) (le *LEObj, err error) {
+
+	le, err = ReadLEObj(acntNam)
+	if err != nil {return nil, fmt.Errorf("ReadLEObj: %v", err)}
+
+	client, err := GetLEClient(acntNam, dbg)
+	if err != nil {return nil, fmt.Errorf("GetLEClient: %v", err)}
+
+	if !IsFileKeyStore(le.KeyStore) {return rolloverKeyStore(client, le)}
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {return nil, fmt.Errorf("Generate Key: %v", err)}
+
+	privPem, pubPem, err := encodeAcntKey(newKey)
+	if err != nil {return nil, fmt.Errorf("encodeAcntKey: %v", err)}
+
+	// the new key is saved before the key change, so that it is not lost if the program fails afterwards
+	newPrivFilnam := le.PrivKeyFilnam + ".new"
+	newPubFilnam := le.PubKeyFilnam + ".new"
+	err = os.WriteFile(newPrivFilnam, privPem, 0600)
+	if err != nil {return nil, fmt.Errorf("os.WriteFile: %v", err)}
+	err = os.WriteFile(newPubFilnam, pubPem, 0644)
+	if err != nil {return nil, fmt.Errorf("os.WriteFile: %v", err)}
+
+	ctx := context.Background()
+	err = client.AccountKeyRollover(ctx, newKey)
+	if err != nil {
+		os.Remove(newPrivFilnam)
+		os.Remove(newPubFilnam)
+		return nil, fmt.Errorf("client.AccountKeyRollover: %v", err)
+	}
+	log.Printf("CA accepted the new account key\n")
+
+	// from here on the CA only accepts the new key
+	now := time.Now()
+	archDir := KeyArchiveDir(le)
+	err = os.MkdirAll(archDir, 0700)
+	if err != nil {return nil, fmt.Errorf("os.MkdirAll: %v -- new key in %s", err, newPrivFilnam)}
+
+	for _, keyFilnam := range []string{le.PrivKeyFilnam, le.PubKeyFilnam} {
+		archFilnam, err := archiveKeyFil(keyFilnam, archDir, now)
+		if err != nil {return nil, fmt.Errorf("archiveKeyFil: %v -- new key in %s", err, newPrivFilnam)}
+		if dbg {log.Printf("archived %s as %s\n", keyFilnam, archFilnam)}
+	}
+
+	err = os.Rename(newPrivFilnam, le.PrivKeyFilnam)
+	if err != nil {return nil, fmt.Errorf("os.Rename: %v -- new key in %s", err, newPrivFilnam)}
+	err = os.Rename(newPubFilnam, le.PubKeyFilnam)
+	if err != nil {return nil, fmt.Errorf("os.Rename: %v -- new key in %s", err, newPubFilnam)}
+
+	le.Updated = now
+	err = WriteLEObj(acntNam, le)
+	if err != nil {return nil, fmt.Errorf("WriteLEObj: %v", err)}
+
+	return le, nil
+}
+
+// function that replaces the key of an account whose key is in a key store other than file
+// the new key gets the label <account>_<time>; the old key remains in the key store
+func rolloverKeyStore(client *acme.Client, le *LEObj) (leNew *LEObj, err error) {
+
+	ks, err := NewKeyStore(le.KeyStore)
+	if err != nil {return nil, fmt.Errorf("NewKeyStore: %v", err)}
+
+	now := time.Now()
+	newKeyId := le.AcntNam + "_" + now.Format("20060102T150405")
+	newKey, err := ks.GenerateKey(newKeyId, KeyEc256)
+	if err != nil {return nil, fmt.Errorf("key store %s: GenerateKey: %v", ks.Name(), err)}
+
+	err = client.AccountKeyRollover(context.Background(), newKey)
+	if err != nil {return nil, fmt.Errorf("client.AccountKeyRollover: %v", err)}
+	log.Printf("CA accepted the new account key %s; the old key %s remains in key store %s\n", newKeyId, AcntKeyId(le), ks.Name())
+
+	le.KeyId = newKeyId
+	le.Updated = now
+	err = WriteLEObj(le.AcntNam, le)
+	if err != nil {return nil, fmt.Errorf("WriteLEObj: %v -- new key %s", err, newKeyId)}
+	return le, nil
+}
+
+// function that removes the archived keys of an account that are older than keepDays
+// accounts with a key store other than file have no archive
+func PruneKeyArchive(le *LEObj, keepDays int, now time.Time) (removed []string, err error) {
+
+	if !IsFileKeyStore(le.KeyStore) {return nil, nil}
+
+	archDir := KeyArchiveDir(le)
+	entries, err := os.ReadDir(archDir)
+	if err != nil {
+		if os.IsNotExist(err) {return nil, nil}
+		return nil, fmt.Errorf("os.ReadDir: %v", err)
+	}
+
+	// the archive directory is shared by the accounts of the folder
+	prefixes := []string{}
+	for _, keyFilnam := range []string{le.PrivKeyFilnam, le.PubKeyFilnam} {
+		base := filepath.Base(keyFilnam)
+		prefixes = append(prefixes, strings.TrimSuffix(base, filepath.Ext(base)) + "_")
+	}
+
+	cutoff := now.Add(-time.Duration(keepDays) * 24 * time.Hour)
+	for _, entry := range entries {
+		if entry.IsDir() {continue}
+		if !strings.HasPrefix(entry.Name(), prefixes[0]) && !strings.HasPrefix(entry.Name(), prefixes[1]) {continue}
+		info, err := entry.Info()
+		if err != nil {return removed, fmt.Errorf("entry.Info: %v", err)}
+		if !info.ModTime().Before(cutoff) {continue}
+
+		filnam := filepath.Join(archDir, entry.Name())
+		err = os.Remove(filnam)
+		if err != nil {return removed, fmt.Errorf("os.Remove: %v", err)}
+		removed = append(removed, filnam)
+	}
+	return removed, nil
+}
@@ -0,0 +1,139 @@
+// tlsAlpn01.go
+// solver for the acme tls-alpn-01 challenge
+// the solver serves the self-signed acme-tls/1 challenge certificate on its own tls listener
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package certLib
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+const ChalTlsAlpn01 = "tls-alpn-01"
+
+// tls-alpn-01 configuration of a csr list
+type TlsAlpn01Cfg struct {
+	// listen address; default :443
+	Addr string `yaml:"addr"`
+}
+
+type TlsAlpn01Solver struct {
+	Cfg TlsAlpn01Cfg
+	Dbg bool
+	mu sync.Mutex
+	certs map[string]*tls.Certificate
+	ln net.Listener
+}
+
+// function that creates a tls-alpn-01 solver
+func NewTlsAlpn01Solver(cfg TlsAlpn01Cfg) (solver *TlsAlpn01Solver) {
+
+	if len(cfg.Addr) == 0 {cfg.Addr = ":443"}
+	solver = &TlsAlpn01Solver{
+		Cfg: cfg,
+		certs: make(map[string]*tls.Certificate),
+	}
+	return solver
+}
+
+// function that selects the challenge certificate for the server name of the hello message
+// only connections that offer the acme-tls/1 protocol are served
+func (solver *TlsAlpn01Solver) getCertificate(hello *tls.ClientHelloInfo) (cert *tls.Certificate, err error) {
+
+	if solver.Dbg {log.Printf("tls-alpn-01: hello server name: %s protos: %v\n", hello.ServerName, hello.SupportedProtos)}
+
+	acmeProto := false
+	for _, proto := range hello.SupportedProtos {
+		if proto == acme.ALPNProto {
+			acmeProto = true
+			break
+		}
+	}
+	if !acmeProto {return nil, fmt.Errorf("tls-alpn-01: no %s protocol offered!", acme.ALPNProto)}
+
+	solver.mu.Lock()
+	cert, ok := solver.certs[strings.ToLower(hello.ServerName)]
+	solver.mu.Unlock()
+	if !ok {return nil, fmt.Errorf("tls-alpn-01: no challenge certificate for %s!", hello.ServerName)}
+	return cert, nil
+}
+
+// function that starts the tls listener
+func (solver *TlsAlpn01Solver) Start() (err error) {
+
+	if solver.ln != nil {return nil}
+
+	tlsCfg := &tls.Config{
+		GetCertificate: solver.getCertificate,
+		NextProtos: []string{acme.ALPNProto},
+		MinVersion: tls.VersionTLS12,
+	}
+
+	ln, err := tls.Listen("tcp", solver.Cfg.Addr, tlsCfg)
+	if err != nil {return fmt.Errorf("tls.Listen: %v", err)}
+	solver.ln = ln
+
+	go solver.serve(ln)
+	return nil
+}
+
+// the validation is done in the handshake; the connection is closed afterwards
+func (solver *TlsAlpn01Solver) serve(ln net.Listener) {
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if solver.Dbg {log.Printf("tls-alpn-01 Accept: %v\n", err)}
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			tlsConn, ok := conn.(*tls.Conn)
+			if !ok {return}
+			err := tlsConn.Handshake()
+			if err != nil && solver.Dbg {log.Printf("tls-alpn-01 Handshake: %v\n", err)}
+		}(conn)
+	}
+}
+
+func (solver *TlsAlpn01Solver) Stop() (err error) {
+
+	if solver.ln == nil {return nil}
+	err = solver.ln.Close()
+	solver.ln = nil
+	if err != nil {return fmt.Errorf("tls-alpn-01 Close: %v", err)}
+	return nil
+}
+
+// function that serves the challenge certificate for a domain
+// the certificate is obtained with acme.Client.TLSALPN01ChallengeCert
+func (solver *TlsAlpn01Solver) Present(domain string, cert tls.Certificate) (err error) {
+
+	if len(domain) == 0 {return fmt.Errorf("no domain!")}
+	if len(cert.Certificate) == 0 {return fmt.Errorf("no challenge certificate for %s!", domain)}
+
+	solver.mu.Lock()
+	solver.certs[strings.ToLower(domain)] = &cert
+	solver.mu.Unlock()
+	return nil
+}
+
+func (solver *TlsAlpn01Solver) CleanUp(domain string) (err error) {
+
+	solver.mu.Lock()
+	delete(solver.certs, strings.ToLower(domain))
+	solver.mu.Unlock()
+	return nil
+}
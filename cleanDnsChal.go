@@ -15,11 +15,10 @@ import (
 	"log"
 	"fmt"
 	"os"
-	"net"
+//	"net"
 //	"time"
-	"strings"
+//	"strings"
 
-    cfLib "acme/acmeDns/cfLib"
 	certLib "acme/acmeDns/certLib"
     util "github.com/prr123/utility/utilLib"
 )
@@ -32,7 +31,7 @@ func main() {
     flags:=[]string{"dbg","csr"}
 
 	useStr := "cleanSnsChal [/csr=csrfile] [/dbg]"
-	helpStr := "program that expunges Dns challenge records and cleans up the csr file\n"
+	helpStr := "program that expunges Dns challenge records and cleans up the state of the csr list\n"
 
 	csrFilnam := "csrTest.yaml"
 	if numarg > 3 {
@@ -72,25 +71,14 @@ func main() {
     if dbg {certLib.PrintCertObj(certObj)}
 
     csrFilnam = certObj.CsrDir + csrFilnam
-	zoneFilnam:= certObj.ZoneDir + "/cfDomainsShort.yaml"
-	cfApiFilnam := certObj.CfApiFilnam
 
 	log.Printf("debug: %t\n", dbg)
-	log.Printf("Using zone file: %s\n", zoneFilnam)
 	log.Printf("Using csr file: %s\n", csrFilnam)
 
-	// reading all domain names served by cloudflare
-    zoneList, err := cfLib.ReadZoneShortFile(zoneFilnam)
-    if err != nil {log.Fatalf("ReadZoneFileShort: %v\n", err)}
-
-	log.Printf("success reading all cf zones!\n")
-	if dbg {cfLib.PrintZoneList(zoneList)}
-
-	numZones := len(zoneList.Zones)
-
-	log.Printf("Acme Chal Domain Target: %d\n", numZones)
-	if numZones == 0 {log.Fatalf("no domains in file: %s\n", zoneFilnam)}
-
+	// a second run on the same csr file fails here
+	csrLock, err := certLib.LockCsrFil(csrFilnam)
+	if err != nil {log.Fatalf("LockCsrFil: %v", err)}
+	defer csrLock.Unlock()
 
 	// read list of all domains for Acme Challenge
     csrList, err := certLib.ReadCsrFil(csrFilnam)
@@ -102,27 +90,50 @@ func main() {
 
 	if dbg {certLib.PrintCsrList(csrList)}
 
-	acmeDomList := make([]cfLib.ZoneAcme, numAcmeDom)
+	leAcnt, err := certLib.ReadLEObj(csrList.AcntName)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
+
+	// get the dns provider selected in the csr file or the account file
+	dnsProvNam := certLib.GetDnsProviderNam(csrList, leAcnt)
+	dnsProv, err := certLib.NewDnsProvider(dnsProvNam, certObj)
+	if err != nil {log.Fatalf("NewDnsProvider: %v\n", err)}
+	log.Printf("success: init dns provider %s\n", dnsProv.Name())
+
+	// reading all domain names served by the dns provider
+	zoneList, err := dnsProv.Zones()
+	if err != nil {log.Fatalf("dnsProv.Zones: %v\n", err)}
+
+	numZones := len(zoneList)
+
+	log.Printf("Acme Chal Domain Target: %d\n", numZones)
+	if numZones == 0 {log.Fatalf("no zones found for dns provider: %s\n", dnsProv.Name())}
+
+	acmeDomList := make([]certLib.ChalRec, 0, numAcmeDom)
 	// see whether acme domains are in zoneList
 
 	count:=0
 	for i:= 0; i< numAcmeDom; i++ {
 		acmeDomNam := csrList.Domains[i].Domain
-		for j:=0; j< numZones; j++ {
-			if acmeDomNam == zoneList.Zones[j].Name {
-				acmeDomList[i].Name = acmeDomNam
-				acmeDomList[i].Id = zoneList.Zones[j].Id
-				count++
-				break
-			}
+		zone, err := certLib.FindZone(acmeDomNam, zoneList)
+		if err != nil {
+			log.Printf("FindZone: %v\n", err)
+			continue
 		}
+		chalRec := certLib.ChalRec{
+			Domain: acmeDomNam,
+			Zone: zone,
+			Name: certLib.ChalRecName(acmeDomNam),
+		}
+		chalRec.RelName = certLib.RelRecName(chalRec.Name, zone.Name)
+		acmeDomList = append(acmeDomList, chalRec)
+		count++
 	}
 
-	if count == 0 {log.Fatalf("no matching acme domains found in cf list!\n")}
+	if count == 0 {log.Fatalf("no matching acme domains found in the zone list!\n")}
 	if count == numAcmeDom {
-		log.Printf("all csr domains found in cd list!\n")
+		log.Printf("all csr domains found in the zone list!\n")
 	} else {
-		log.Printf("only %d out %d csr domains found in cd list!\n", count, numAcmeDom)
+		log.Printf("only %d out %d csr domains found in the zone list!\n", count, numAcmeDom)
 	}
 
 	numAcmeDom = count
@@ -130,34 +141,37 @@ func main() {
 
 	fmt.Println("*************************************")
 	for j:= 0; j< numAcmeDom; j++ {
-		log.Printf("domain[%d]: %-20s id: %s\n", j+1, acmeDomList[j].Name, acmeDomList[j].Id)
+		log.Printf("domain[%d]: %-20s zone: %s id: %s\n", j+1, acmeDomList[j].Domain, acmeDomList[j].Zone.Name, acmeDomList[j].Zone.Id)
 	}
 	fmt.Println("*************************************")
 
+	// propagation checker that queries the authoritative name servers of the zones
+	propChk := certLib.NewPropChecker(csrList.Propagation)
+	propChk.Dbg = dbg
+
 	// test acme domains for challenge records
 	foundAcme := 0
+	acmeRec := make([]bool, numAcmeDom)
 	for i:=0; i< numAcmeDom; i++ {
-		acmeDomList[i].AcmeRec = false
-		domain := acmeDomList[i].Name
-		acmeDomain := "_acme-challenge." + domain
+		domain := acmeDomList[i].Domain
+		acmeDomain := acmeDomList[i].Name
 
 		log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
 
-		txtrecs, err := net.LookupTXT(acmeDomain)
-		if err == nil {
-			log.Printf("received txtrec from Lookup\n")
-			if dbg {fmt.Printf("txtrecs [%d]: %s\n", len(txtrecs), txtrecs[0])}
-			acmeDomList[i].AcmeRec = true
-			foundAcme++
-		} else {
-			errStr := err.Error()
-			idx := strings.Index(errStr, "127.0.0.53:53")
-			if idx == -1 {
-				log.Printf("domain %s: lookup error:%v\n", domain, err)
-			} else {
-				log.Printf("domain: %s -- no acme challenge record found!\n", domain)
-			}
+		// query the authoritative name servers of the zone directly
+		txtrecs, err := propChk.LookupTxt(acmeDomList[i].Zone.Name, acmeDomain)
+		if err != nil {
+			log.Printf("domain %s: lookup error:%v\n", domain, err)
+			continue
 		}
+		if len(txtrecs) == 0 {
+			log.Printf("domain: %s -- no acme challenge record found!\n", domain)
+			continue
+		}
+		log.Printf("received txtrec from Lookup\n")
+		if dbg {fmt.Printf("txtrecs [%d]: %v\n", len(txtrecs), txtrecs)}
+		acmeRec[i] = true
+		foundAcme++
 	}
 
 	if foundAcme == 0 {
@@ -171,33 +185,22 @@ func main() {
 	// clean-up Dns records
 	log.Printf("found %d Domains with residual DNS Challenge records!\n", foundAcme)
 
-	// get api for DNS use default yaml file
-	apiObj, err := cfLib.InitCfApi(cfApiFilnam)
-	if err != nil {log.Fatalf("cfLib.InitCfApi: %v\n", err)}
-	log.Printf("success: init cfapi\n")
-
 	for i:=0; i< numAcmeDom; i++ {
-		if !acmeDomList[i].AcmeRec {continue}
+		if !acmeRec[i] {continue}
 
-		domain := acmeDomList[i].Name
+		domain := acmeDomList[i].Domain
 		log.Printf("cleaning domain[%d]: %s\n", i+1, domain)
 
-		zoneId := acmeDomList[i].Id
-		dnsRecs, err := apiObj.ListDnsRecords(zoneId)
-		if err != nil {log.Fatalf("domain[%d]: %s api.ListDNSRecords: %v\n", i+1, domain, err)}
-
-		if dbg {cfLib.PrintDnsRecs(dnsRecs)}
-
-		dnsId := ""
-        for j:=0; j< len(*dnsRecs); j++ {
-			idx := strings.Index((*dnsRecs)[j].Name, "_acme-challenge.")
-            if idx == 0 {
-                dnsId = (*dnsRecs)[j].ID
-				log.Printf("found acme challenge record[%d] in domain %s\n", j+1, domain)
-				err = apiObj.DelDnsRec(zoneId, dnsId)
-				if err != nil {log.Fatalf("api.DeleteDNSRecord: %v\n", err)}
-				log.Println("deleted Acme Dns Record")
-			}
+		dnsRecs, err := dnsProv.ListChalRecs(acmeDomList[i].Zone)
+		if err != nil {log.Fatalf("domain[%d]: %s dnsProv.ListChalRecs: %v\n", i+1, domain, err)}
+
+		if dbg {certLib.PrintChalRecs(dnsRecs)}
+
+        for j:=0; j< len(dnsRecs); j++ {
+			log.Printf("found acme challenge record[%d] in domain %s\n", j+1, domain)
+			err = dnsProv.CleanUp(&dnsRecs[j])
+			if err != nil {log.Fatalf("dnsProv.CleanUp: %v\n", err)}
+			log.Println("deleted Acme Dns Record")
 		}
 	}
 
@@ -6,6 +6,7 @@
 //
 // code copied from V2
 // single order for multiple domains
+// the issuance is a state machine that is saved in the state file of the csr list (see certLib/issueState.go)
 //
 
 package main
@@ -17,11 +18,11 @@ import (
 	"fmt"
 	"os"
 	"time"
-	"net"
+//	"net"
 	"strings"
+	"crypto/x509"
 	"golang.org/x/crypto/acme"
 
-    cfLib "acme/acmeDns/cfLib"
 	certLib "acme/acmeDns/certLib"
     util "github.com/prr123/utility/utilLib"
 )
@@ -31,19 +32,24 @@ func main() {
 
 	numarg := len(os.Args)
 	dbg := true
-    flags:=[]string{"dbg","csr"}
+    flags:=[]string{"dbg","csr","acnt"}
 
 	// default file
     csrFilnam := "csrTest.yaml"
-	newOrder := &acme.Order{}
 
-	useStr := "./createCertsV3 [/csr=csrfile] [/dbg]"
+	useStr := "./createCertsV3 [/csr=csrfile] [/acnt=account] [/dbg]"
 	helpStr := "program that creates one certificate for all domains listed in the file csrList.yaml\n"
-	helpStr += "requirements: - a file listing all cloudflare domains/zones controlled by this account\n"
-	helpStr += "              - a cloudflare authorisation file with a token that permits DNS record changes in the direcory cloudflare/token\n"
+	helpStr += "each domain selects its challenge with chaltype in the csr file: dns-01 (default), http-01 or tls-alpn-01\n"
+	helpStr += "requirements: - a dns provider (default cloudflare) selected with dnsProvider in the csr file or the account file\n"
+	helpStr += "              - for cloudflare: a file listing all cloudflare domains/zones controlled by this account\n"
+	helpStr += "                and a cloudflare authorisation file with a token that permits DNS record changes in the direcory cloudflare/token\n"
+	helpStr += "              - http-01: a listener address (default :80) or a webroot directory set with http01 in the csr file\n"
+	helpStr += "              - tls-alpn-01: a listener address (default :443) set with tlsAlpn01 in the csr file\n"
 	helpStr += "              - a csr yaml file located in $LEAcnt/csrList\n"
+	helpStr += "/acnt: account used instead of the account of the csr file, e.g. an account with a different CA profile\n"
+	helpStr += "the issue state is saved in LEAcnt/csrState after each step; a new run resumes an interrupted issuance\n"
 
-	if numarg > 4 {
+	if numarg > 5 {
 		fmt.Println("too many arguments in cl!")
 		fmt.Println("usage: %s\n", useStr)
 		os.Exit(-1)
@@ -75,37 +81,30 @@ func main() {
 		log.Printf("csrList: %s\n", csrFilnam)
 	}
 
+	// account that replaces the account of the csr file
+	acntNam := ""
+	val, ok = flagMap["acnt"]
+	if ok {
+		if val.(string) == "none" {log.Fatalf("no account name provided with /acnt flag!")}
+		acntNam = val.(string)
+	}
+
 	certObj, err := certLib.InitCertLib()
 	if err != nil {log.Fatalf("InitCertLib: %v\n", err)}
     if dbg {certLib.PrintCertObj(certObj)}
 
-	zoneFilnam := certObj.ZoneFilnam
-	cfApiFilnam := certObj.CfApiFilnam
 	csrFilnam = certObj.CsrDir + csrFilnam
 
 	log.Printf("debug: %t\n", dbg)
-	log.Printf("Using zone file: %s\n", zoneFilnam)
 	log.Printf("Using csr file: %s\n", csrFilnam)
 
-	cfApiObj, err := cfLib.InitCfApi(cfApiFilnam)
-	if err != nil {log.Fatalf("cfLib.InitCfApi: %v\n", err)}
-	log.Printf("success: init cf api\n")
-
     // creating context
     ctx := context.Background()
 
-	// reading all domain names served by cloudflare
-    zoneList, err := cfLib.ReadZoneShortFile(zoneFilnam)
-    if err != nil {log.Fatalf("ReadZoneFileShort: %v\n", err)}
-
-	log.Printf("success reading all cf zones!\n")
-	if dbg {cfLib.PrintZoneList(zoneList)}
-
-	numZones := len(zoneList.Zones)
-
-	log.Printf("Acme Chal Domain Target: %d\n", numZones)
-	if numZones == 0 {log.Fatalf("no domains in file: %s\n", zoneFilnam)}
-
+	// a second run on the same csr file fails here
+	csrLock, err := certLib.LockCsrFil(csrFilnam)
+	if err != nil {log.Fatalf("LockCsrFil: %v", err)}
+	defer csrLock.Unlock()
 
 	// read list of all domains for Acme Challenge
     csrList, err := certLib.ReadCsrFil(csrFilnam)
@@ -115,84 +114,323 @@ func main() {
 	numAcmeDom := len(csrList.Domains)
     log.Printf("found %d acme Domains\n", numAcmeDom)
 
-	authIdList := make([]acme.AuthzID, numAcmeDom)
-
 	if dbg {certLib.PrintCsrList(csrList)}
 
-	acmeDomList := make([]cfLib.ZoneAcme, numAcmeDom)
-	// see whether acme domains are in zoneList
+	err = certLib.CheckChalTypes(csrList)
+	if err != nil {log.Fatalf("CheckChalTypes: %v\n", err)}
+
+	// all domains share the certificate and its key
+	keyType, err := certLib.CsrKeyType(csrList)
+	if err != nil {log.Fatalf("CsrKeyType: %v\n", err)}
+	log.Printf("key type: %s\n", keyType)
+
+	// with key policy reuse the renewal keeps the key of the current certificate
+	keyPolicy, keyMaxAge, err := certLib.CsrKeyPolicy(csrList)
+	if err != nil {log.Fatalf("CsrKeyPolicy: %v\n", err)}
+	log.Printf("key policy: %s\n", keyPolicy)
+
+	keyStore, err := certLib.NewKeyStore(csrList.KeyStore)
+	if err != nil {log.Fatalf("NewKeyStore: %v\n", err)}
+	log.Printf("key store: %s\n", keyStore.Name())
+
+	// a dual csr list finalizes the validated order with an ecdsa key and a second order with an rsa key
+	keyTypes := []string{keyType}
+	if csrList.Dual != nil {
+		ecType, rsaType, err := certLib.DualKeyTypes(csrList.Dual)
+		if err != nil {log.Fatalf("DualKeyTypes: %v\n", err)}
+		keyTypes = []string{ecType, rsaType}
+		log.Printf("dual certificates: %s and %s\n", ecType, rsaType)
+	}
 
-	// get api for DNS use default yaml file
-
-	foundAllDom := true
-	for i:= 0; i< numAcmeDom; i++ {
-		acmeDomNam := csrList.Domains[i].Domain
-		foundDom := false
-		for j:=0; j< numZones; j++ {
-			if zoneList.Zones[j].Name == acmeDomNam {
-				acmeDomList[i].Name = acmeDomNam
-				acmeDomList[i].Id = zoneList.Zones[j].Id
-				foundDom = true
-				break
-			}
+	certNam, err := certLib.GenerateCertName(csrList.Domains[0].Domain)
+	if err != nil {log.Fatalf("GenerateCertName: %v", err)}
+	if dbg {log.Printf("certNam: %s\n", certNam)}
+
+	certNams := []string{certNam}
+	if csrList.Dual != nil {
+		certNams = []string{certLib.DualCertNam(certNam, keyTypes[0]), certLib.DualCertNam(certNam, keyTypes[1])}
+	}
+
+	// the accounts are tried in order; a failure that another CA may not have moves the issuance to the next account
+	if len(acntNam) == 0 {acntNam = csrList.AcntName}
+	acntList := certLib.IssueAccounts(acntNam, csrList.Fallback)
+
+	// the issue state of the csr list tells where the previous run stopped
+	if csrList.Issue.State == certLib.IssueCleanedUp {csrList.Issue = certLib.IssueState{}}
+	if csrList.Issue.State == certLib.IssueNew {legacyIssueState(csrList, acntList[0])}
+	err = certLib.CheckIssueState(csrList)
+	if err != nil {log.Fatalf("CheckIssueState: %v\n", err)}
+
+	issue := &csrList.Issue
+	// a failover saves the state new with the next account
+	if issue.State == certLib.IssueNew && len(issue.Account) == 0 {
+		issue.Account = acntList[0]
+		issue.AcntIdx = 0
+		issue.Certs = nil
+		// ARI: the new order replaces the current certificate of the csr list
+		issue.ReplacesId = replacesCertId(certObj.CertDir, certNams[0], dbg)
+	} else {
+		log.Printf("resuming issuance in state %s with account %s\n", issue.State, issue.Account)
+		if issue.AcntIdx >= len(acntList) || acntList[issue.AcntIdx] != issue.Account {
+			log.Fatalf("account %s of the issue state is not account %d of the csr file!", issue.Account, issue.AcntIdx)
 		}
-		if !foundDom {
-			log.Printf("domain %s is not contained in the cf Zone List\n", acmeDomNam)
-			foundAllDom = false
+	}
+	if len(issue.Certs) == 0 {
+		for k:=0; k< len(certNams); k++ {
+			issue.Certs = append(issue.Certs, certLib.IssueCert{CertNam: certNams[k], KeyType: keyTypes[k]})
 		}
 	}
+	if len(issue.Certs) != len(certNams) {log.Fatalf("the issue state has %d certificates instead of %d!", len(issue.Certs), len(certNams))}
+	log.Printf("account: %s\n", issue.Account)
+	if dbg {certLib.PrintIssueState(csrList)}
+
+	// get the dns provider selected in the csr file or the account file
+	dnsAcnt, err := certLib.ReadLEObj(acntList[0])
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
+
+	var dnsProv certLib.DNSProvider
+	var zoneList []certLib.DnsZone
+	if certLib.UsesChalType(csrList, certLib.ChalDns01) {
+		dnsProvNam := certLib.GetDnsProviderNam(csrList, dnsAcnt)
+		dnsProv, err = certLib.NewDnsProvider(dnsProvNam, certObj)
+		if err != nil {log.Fatalf("NewDnsProvider: %v\n", err)}
+		log.Printf("success: init dns provider %s\n", dnsProv.Name())
+
+		// reading all domain names served by the dns provider
+		zoneList, err = dnsProv.Zones()
+		if err != nil {log.Fatalf("dnsProv.Zones: %v\n", err)}
+
+		numZones := len(zoneList)
+
+		log.Printf("Acme Chal Domain Target: %d\n", numZones)
+		if numZones == 0 {log.Fatalf("no zones found for dns provider: %s\n", dnsProv.Name())}
+	}
 
-	if !foundAllDom {log.Fatalf("csr list file contains domains that are not in the cf account domain list!")}
+	// the http-01 solver serves the key authorizations on its own listener or writes them into a webroot
+	var httpSolver *certLib.Http01Solver
+	if certLib.UsesChalType(csrList, certLib.ChalHttp01) {
+		httpSolver = certLib.NewHttp01Solver(csrList.Http01)
+		httpSolver.Dbg = dbg
+		err = httpSolver.Start()
+		if err != nil {log.Fatalf("http01 solver Start: %v\n", err)}
+		defer httpSolver.Stop()
+		log.Printf("success: started http01 solver addr: %s webroot: %s\n", httpSolver.Cfg.Addr, httpSolver.Cfg.Webroot)
+	}
+
+	// the tls-alpn-01 solver serves the acme-tls/1 challenge certificates on its own listener
+	var tlsSolver *certLib.TlsAlpn01Solver
+	if certLib.UsesChalType(csrList, certLib.ChalTlsAlpn01) {
+		tlsSolver = certLib.NewTlsAlpn01Solver(csrList.TlsAlpn01)
+		tlsSolver.Dbg = dbg
+		err = tlsSolver.Start()
+		if err != nil {log.Fatalf("tls-alpn-01 solver Start: %v\n", err)}
+		defer tlsSolver.Stop()
+		log.Printf("success: started tls-alpn-01 solver addr: %s\n", tlsSolver.Cfg.Addr)
+	}
 
-	allChalRec := true
-	noChalRec := true
+	// see whether acme domains are in zoneList
+	chalRecs, err := certLib.GetChalRecs(csrList, zoneList)
+	if err != nil {log.Fatalf("GetChalRecs: %v\n", err)}
+
+	// propagation checker that queries the authoritative name servers of the zones
+	propChk := certLib.NewPropChecker(csrList.Propagation)
+	propChk.Dbg = dbg
+
+	// Authorize all domains provided in the cmd line args.
+	authIdList := make([]acme.AuthzID, numAcmeDom)
 	for i:=0; i< numAcmeDom; i++ {
-		recId := csrList.Domains[i].ChalRecId
-		if len(recId) >0 {
-			noChalRec = false
-		} else {
-			allChalRec = false
-		}
+		authIdList[i].Type = "dns"
+		authIdList[i].Value = chalRecs[i].Domain
+	}
+
+	run := &issueRun{
+		ctx: ctx,
+		dbg: dbg,
+		csrFilnam: csrFilnam,
+		csrFil: strings.TrimPrefix(csrFilnam, certObj.CsrDir),
+		certDir: certObj.CertDir,
+		invFilnam: certObj.InventoryFilnam,
+		csrList: csrList,
+		acntList: acntList,
+		dnsProv: dnsProv,
+		httpSolver: httpSolver,
+		tlsSolver: tlsSolver,
+		chalRecs: chalRecs,
+		propChk: propChk,
+		authIdList: authIdList,
+		keyStore: keyStore,
+		keyPolicy: keyPolicy,
+		keyMaxAge: keyMaxAge,
+	}
+
+	// a new issuance must not find challenge records of an earlier order
+	if issue.State == certLib.IssueNew {run.checkOldRecs()}
+
+	run.setAccount(issue.Account)
+
+	// the solvers do not survive a restart; publish the key authorizations of the resumed order again
+	if certLib.IssueStateReached(csrList, certLib.IssueAuthorizing) && !certLib.IssueStateReached(csrList, certLib.IssueOrderReady) {
+		run.republish()
+	}
+
+	for csrList.Issue.State != certLib.IssueCleanedUp {
+		err = run.step()
+		if err != nil {run.failover(err)}
+	}
+
+	log.Printf("success creating Certs\n")
+}
+
+// data of an issuance run
+type issueRun struct {
+	ctx context.Context
+	dbg bool
+	csrFilnam string
+	// csr file name relative to the csr directory
+	csrFil string
+	certDir string
+	// inventory of the issued certificates
+	invFilnam string
+	csrList *certLib.CsrList
+	acntList []string
+	leAcnt *certLib.LEObj
+	caProf *certLib.CAProfile
+	client *acme.Client
+	dnsProv certLib.DNSProvider
+	httpSolver *certLib.Http01Solver
+	tlsSolver *certLib.TlsAlpn01Solver
+	chalRecs []certLib.ChalRec
+	propChk *certLib.PropChecker
+	authIdList []acme.AuthzID
+	keyStore certLib.KeyStore
+	keyPolicy string
+	keyMaxAge int
+}
+
+// function that performs the transition of the current issue state
+// errors of the CA are returned for the failover
+func (run *issueRun) step() (err error) {
+
+	switch run.csrList.Issue.State {
+	case certLib.IssueNew:
+		return run.order()
+	case certLib.IssueOrdered, certLib.IssueAuthorizing:
+		return run.authorize()
+	case certLib.IssueRecordsPresented:
+		run.propagate()
+		return nil
+	case certLib.IssuePropagated:
+		return run.accept()
+	case certLib.IssueChallengesAccepted:
+		return run.waitOrder()
+	case certLib.IssueOrderReady:
+		return run.finalize()
+	case certLib.IssueFinalized:
+		return run.download()
+	case certLib.IssueDownloaded:
+		run.cleanup()
+		return nil
+	default:
+		log.Fatalf("unknown issue state: %s\n", run.csrList.Issue.State)
 	}
+	return nil
+}
+
+// function that saves the new issue state of the csr list
+func (run *issueRun) setState(state string) {
+
+	err := certLib.SetIssueState(run.csrFilnam, run.csrList, state)
+	if err != nil {log.Fatalf("SetIssueState %s: %v\n", state, err)}
+	log.Printf("issue state: %s\n", state)
+}
+
+// function that saves the progress within the current issue state
+func (run *issueRun) saveState() {
+
+	err := certLib.SaveIssueState(run.csrFilnam, run.csrList)
+	if err != nil {log.Fatalf("SaveIssueState: %v\n", err)}
+}
+
+// function that selects the account of the issuance
+func (run *issueRun) setAccount(acntNam string) {
+
+	var err error
+	run.leAcnt, err = certLib.ReadLEObj(acntNam)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
+	run.caProf, err = certLib.AcntCAProfile(run.leAcnt)
+	if err != nil {log.Fatalf("AcntCAProfile: %v\n", err)}
+	if run.dbg {certLib.PrintCAProfile(run.caProf)}
 
-	log.Printf("allChalRec %t noChalRec %t\n", allChalRec, noChalRec)
+	run.client, err = certLib.GetLEClient(acntNam, run.dbg)
+	if err != nil {log.Fatalf("could not get Acme Client: certLib.GetLEAcnt: %v\n", err)}
+	log.Printf("success obtaining Acme Client for account %s\n", acntNam)
+}
+
+// function that moves the issuance to the next account after a failure that another CA may not have
+// other failures abort the program
+func (run *issueRun) failover(failErr error) {
+
+	csrList := run.csrList
+	issue := &csrList.Issue
+	acntNam := issue.Account
+	failClass, failover := certLib.ClassifyAcmeErr(failErr)
+	if !failover {log.Fatalf("account %s: issuance failed [%s]: %v\n", acntNam, failClass, failErr)}
+	// from state finalized the certificates exist at the CA of the account; another CA would issue them a second time
+	if certLib.IssueStateReached(csrList, certLib.IssueFinalized) {
+		log.Printf("account %s: state %s failed [%s]: %v -- run again to resume\n", acntNam, issue.State, failClass, failErr)
+		os.Exit(1)
+	}
+	if issue.AcntIdx + 1 >= len(run.acntList) {log.Fatalf("account %s: issuance failed [%s]: %v\n", acntNam, failClass, failErr)}
+	issue.AcntIdx++
+	log.Printf("account %s: issuance failed [%s]: %v -- failover to account %s\n", acntNam, failClass, failErr, run.acntList[issue.AcntIdx])
+
+	// the challenges of the failed order are removed; the next CA starts with a fresh order
+	err := cleanupChals(csrList, run.chalRecs, run.dnsProv, run.httpSolver, run.tlsSolver)
+	if err != nil {log.Fatalf("cleanupChals: %v\n", err)}
+	certLib.ResetCsrOrder(csrList)
+
+	issue.Account = run.acntList[issue.AcntIdx]
+	// the current certificate was not necessarily issued by this CA
+	issue.ReplacesId = ""
+	for k:=0; k< len(issue.Certs); k++ {
+		issue.Certs[k].OrderUrl = ""
+		issue.Certs[k].CertUrl = ""
+		issue.Certs[k].NewKeyId = ""
+		// the next CA issues each certificate with a new key; a saved certificate of the failed CA is replaced
+		issue.Certs[k].Saved = false
+	}
+	run.setState(certLib.IssueNew)
+	run.setAccount(issue.Account)
+}
 
-	if !allChalRec && !noChalRec {log.Fatalf("error mixed: neither allChal nor noChal are true")}
-	if allChalRec && noChalRec {log.Fatalf("error mixed: allChal and noChal cannot both be true")}
+// function that searches the name servers for challenge records of an earlier order
+func (run *issueRun) checkOldRecs() {
 
-	// test acme domains for challenge records
+	csrList := run.csrList
 	log.Printf("searching for left-over acme records!")
 	oldAcmeRec := false
 	noAcmeRec := true
-	for i:=0; i< numAcmeDom; i++ {
-		acmeDomList[i].AcmeRec = false
-		domain := acmeDomList[i].Name
-		acmeDomain := "_acme-challenge." + domain
+	for i:=0; i< len(csrList.Domains); i++ {
+		if certLib.GetChalType(csrList.Domains[i]) != certLib.ChalDns01 {continue}
+		acmeDomain := run.chalRecs[i].Name
 
 		log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
 
-		txtrecs, err := net.LookupTXT(acmeDomain)
-		if err == nil {
-			log.Printf("received txtrec from Lookup\n")
-			if dbg {
-				fmt.Printf("txtrecs[%d]: %s\n", len(txtrecs), txtrecs[0])
-				fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
-			}
-			if len(txtrecs[0]) > 0 {noAcmeRec = false}
-			if txtrecs[0] != csrList.Domains[i].TokVal {
-				acmeDomList[i].AcmeRec = true
-				oldAcmeRec = true
-			}
-		} else {
-			errStr := err.Error()
-//			log.Printf("*** errStr: %s\n", errStr)
-			idx := strings.Index(errStr, "127.0.0.53:53")
-			if idx>-1 {
-				log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
-			} else {
-				log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)
-			}
+		// query the authoritative name servers of the zone directly
+		txtrecs, err := run.propChk.LookupTxt(run.chalRecs[i].Zone.Name, acmeDomain)
+		if err != nil {log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)}
+		if len(txtrecs) == 0 {
+			log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
+			continue
+		}
+		log.Printf("received txtrec from Lookup\n")
+		if run.dbg {
+			fmt.Printf("txtrecs[%d]: %v\n", len(txtrecs), txtrecs)
+			fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
 		}
+		noAcmeRec = false
+		// a wildcard and its apex share the record name
+		if certLib.HasOldTxt(txtrecs, certLib.ChalRecVals(run.chalRecs, acmeDomain)) {oldAcmeRec = true}
 	}
 
 	if oldAcmeRec {log.Fatalf("lookup found acme Dns chal records!")}
@@ -202,262 +440,523 @@ func main() {
 	} else {
 		log.Printf("lookup no OldAcme Recs but new Acme Recs found\n")
 	}
+}
 
-    client, err := certLib.GetLEClient(csrList.AcntName, dbg)
-    if err != nil {log.Fatalf("could not get Acme Client: certLib.GetLEAcnt: %v\n", err)}
-	log.Printf("success obtaining Acme Client\n")
+// state new: creates the order
+func (run *issueRun) order() (err error) {
 
-	// lookup needs to be declared before goto statement
-	lookup:= true
+	csrList := run.csrList
+	replacesId := csrList.Issue.ReplacesId
 
-	if allChalRec {
-		log.Printf("found all domains contain acme chal recs; going to lookup!")
-		goto ProcOrder
+	// lets encrypt does not accept preauthorisation
+	newOrder, err := certLib.AuthorizeOrderAri(run.ctx, run.client, run.authIdList, replacesId)
+	if err != nil && len(replacesId) > 0 {
+		// the CA may reject the replaces field, e.g. if the certificate was already replaced
+		log.Printf("AuthorizeOrderAri: %v -- sending order without replaces\n", err)
+		newOrder, err = run.client.AuthorizeOrder(run.ctx, run.authIdList)
 	}
-
-	// Authorize all domains provided in the cmd line args.
-	for i:=0; i< numAcmeDom; i++ {
-		authIdList[i].Type = "dns"
-		authIdList[i].Value = acmeDomList[i].Name
+	if err != nil {
+		log.Printf("client.AuthorizeOrder: %v\n",err)
+		return err
 	}
+	log.Printf("received Authorization Order!\n")
+	if run.dbg {certLib.PrintOrder(*newOrder)}
 
-	// lets encrypt does not accept preauthorisation
-	// var orderOpt acme.OrderOption
-	// OrderOption is contains optional parameters regarding timing
+	csrList.OrderUrl = newOrder.URI
+	csrList.CertUrl = ""
+	run.setState(certLib.IssueOrdered)
+	return nil
+}
 
-	newOrder, err = client.AuthorizeOrder(ctx, authIdList)
-	if err != nil {log.Fatalf("client.AuthorizeOrder: %v\n",err)}
-	log.Printf("received Authorization Order!\n")
-	if dbg {certLib.PrintOrder(*newOrder)}
+// states ordered and authorizing: publishes the challenge of each domain
+// a domain with ChalRecId was published by an earlier run
+func (run *issueRun) authorize() (err error) {
+
+	csrList := run.csrList
+	ctx := run.ctx
+	client := run.client
+	if csrList.Issue.State == certLib.IssueOrdered {run.setState(certLib.IssueAuthorizing)}
+
+	order, err := client.GetOrder(ctx, csrList.OrderUrl)
+	if err != nil {
+		log.Printf("client.GetOrder: %v\n", err)
+		return err
+	}
 
 	log.Printf("**** Begin Loop ****\n")
 	// need to loop through domains
 
-	for i:=0; i< numAcmeDom; i++ {
-		// acmeZone.Name and domain are the same..
-		domain := authIdList[i].Value
-		log.Printf("domain [%d]: %s\n", i+1, domain)
-
-		url := newOrder.AuthzURLs[i]
-		acmeZone := acmeDomList[i]
+	for j:=0; j< len(order.AuthzURLs); j++ {
+		url := order.AuthzURLs[j]
 
 		auth, err := client.GetAuthorization(ctx, url)
-		if err != nil {log.Fatalf("client.GetAuthorisation: %v\n",err)}
+		if err != nil {
+			log.Printf("client.GetAuthorisation: %v\n",err)
+			return err
+		}
+
+		// the order of the authorizations need not follow the csr list
+		// *.example.com and example.com both have the identifier example.com
+		i, err := certLib.FindAuthDomain(csrList, auth)
+		if err != nil {log.Fatalf("FindAuthDomain: %v\n", err)}
+		domain := run.authIdList[i].Value
+		log.Printf("domain [%d]: %s\n", i+1, domain)
+
+		if len(csrList.Domains[i].ChalRecId) > 0 {
+			log.Printf("%s: challenge already published!\n", domain)
+			continue
+		}
 
 		log.Printf("success getting authorization for domain: %s\n", domain)
-		if dbg {certLib.PrintAuth(auth)}
+		if run.dbg {certLib.PrintAuth(auth)}
 
-		// Pick the DNS challenge, if any.
+		// Pick the challenge selected for the domain, if any.
+		chalType := certLib.GetChalType(csrList.Domains[i])
 		var chal *acme.Challenge
 		for _, c := range auth.Challenges {
-			if c.Type == "dns-01" {
+			if c.Type == chalType {
 				chal = c
 				break
 			}
 		}
 
-		if chal == nil {log.Fatalf("dns-01 challenge is not available for zone %s", domain)}
+		if chal == nil {log.Fatalf("%s challenge is not available for zone %s", chalType, domain)}
 
 		log.Printf("success obtaining challenge\n")
-		if dbg {certLib.PrintChallenge(chal, acmeZone.Name)}
+		if run.dbg {certLib.PrintChallenge(chal, domain)}
+
+		switch chalType {
+		case certLib.ChalHttp01:
+			keyAuth, err := client.HTTP01ChallengeResponse(chal.Token)
+			if err != nil {log.Fatalf("http-01 key authorization for %s: %v", domain, err)}
+
+			err = run.httpSolver.Present(chal.Token, keyAuth)
+			if err != nil {log.Fatalf("httpSolver.Present: %v", err)}
+
+			// the token identifies the published key authorization
+			csrList.Domains[i].ChalRecId = chal.Token
+			log.Printf("%s: success publishing http-01 key authorization!\n", domain)
+
+		case certLib.ChalTlsAlpn01:
+			chalCert, err := client.TLSALPN01ChallengeCert(chal.Token, domain)
+			if err != nil {log.Fatalf("tls-alpn-01 challenge cert for %s: %v", domain, err)}
+
+			err = run.tlsSolver.Present(domain, chalCert)
+			if err != nil {log.Fatalf("tlsSolver.Present: %v", err)}
 
-		// Fulfill the challenge.
-		tokVal, err := client.DNS01ChallengeRecord(chal.Token)
-		if err != nil {log.Fatalf("dns-01 token for %s: %v", domain, err)}
-		log.Printf("success obtaining Dns token: %s\n", tokVal)
+			csrList.Domains[i].ChalRecId = chal.Token
+			log.Printf("%s: success serving tls-alpn-01 challenge certificate!\n", domain)
 
-		recId, err := cfApiObj.AddDnsChalRecord(acmeZone.Id, tokVal)
-		if err != nil {log.Fatalf("AddDnsChalRecord: %v", err)}
-		acmeDomList[i].AcmeId = recId
+		default:
+			// Fulfill the challenge.
+			tokVal, err := client.DNS01ChallengeRecord(chal.Token)
+			if err != nil {log.Fatalf("dns-01 token for %s: %v", domain, err)}
+			log.Printf("success obtaining Dns token: %s\n", tokVal)
+
+			run.chalRecs[i].Value = tokVal
+			run.chalRecs[i].Exp = auth.Expires
+			err = run.dnsProv.Present(&run.chalRecs[i])
+			if err != nil {log.Fatalf("dnsProv.Present: %v", err)}
+
+			csrList.Domains[i].TokVal = tokVal
+			csrList.Domains[i].ChalRecId = run.chalRecs[i].RecId
+
+			if run.dbg {
+				dnsRecs, err := run.dnsProv.ListChalRecs(run.chalRecs[i].Zone)
+				if err != nil {log.Fatalf("domain[%d]: %s dnsProv.ListChalRecs: %v\n", i+1, domain, err)}
+				certLib.PrintChalRecs(dnsRecs)
+			}
+			log.Printf("%s: success creating dns record!\n", domain)
+		}
 
-		csrList.Domains[i].TokVal = tokVal
 		csrList.Domains[i].Token = chal.Token
 		csrList.Domains[i].TokUrl = chal.URI
-		csrList.Domains[i].ChalRecId = recId
 		csrList.Domains[i].TokIssue = time.Now()
 		csrList.Domains[i].TokExp = auth.Expires
 
-		if dbg {
-			zoneId := acmeDomList[i].Id
-	        dnsRecs, err := cfApiObj.ListDnsRecords(zoneId)
-    	    if err != nil {log.Fatalf("domain[%d]: %s apiObj.ListDnsRecords: %v\n", i+1, acmeDomList[i].Name, err)}
-			cfLib.PrintDnsRecs(dnsRecs)
-		}
-
-		log.Printf("%s: success creating dns record!\n", acmeDomList[i].Name)
-
+		// the published challenge is saved before the next one
+		run.saveState()
 	}
-	log.Printf("success creating all dns challenge records!")
+	log.Printf("success creating all challenge records!")
 
 	csrList.LastLU = time.Now()
-	csrList.OrderUrl = newOrder.URI
-	csrList.CertUrl = ""
-	err = certLib.WriteCsrFil(csrFilnam, csrList)
-	if err != nil {log.Fatal("certLib.WriteCsrFil: %v\n", err)}
-
-	// we need to check whether newly add Dns Records have propagated
-	log.Printf("performing lookup for all challenge records")
+	run.setState(certLib.IssueRecordsPresented)
+	return nil
+}
 
-	for i:=0; i< numAcmeDom; i++ {
-		domain := authIdList[i].Value
+// state records-presented: checks whether the challenges are visible
+// if not, the program exits; the next run checks again
+func (run *issueRun) propagate() {
 
-		// check DNS Record via LookUp
-		acmeDomain := "_acme-challenge." + domain
+	csrList := run.csrList
+	lookup := true
 
-		log.Printf("waiting 2 sec before performing ns.Lookup\n")
-		time.Sleep(2 * time.Second)
-		log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
+	// we need to check whether newly add Dns Records have propagated
+	log.Printf("performing lookup for all challenge records")
 
-		rdAttempt := -1
-		for i:= 0; i< 2; i++ {
-			txtrecs, err := net.LookupTXT(acmeDomain)
-			if err == nil {
-				log.Printf("received txtrec from Lookup\n")
-				if dbg {fmt.Printf("txtrecs [%d]: %s\n", len(txtrecs), txtrecs[0])}
-				rdAttempt = i;
-				break
+	for i:=0; i< len(csrList.Domains); i++ {
+		domain := run.authIdList[i].Value
+
+		if certLib.GetChalType(csrList.Domains[i]) == certLib.ChalHttp01 {
+			keyAuth, err := run.client.HTTP01ChallengeResponse(csrList.Domains[i].Token)
+			if err != nil {log.Fatalf("http-01 key authorization for %s: %v", domain, err)}
+			// the check is advisory: the host may not reach its own public address (nat, split dns, proxy)
+			// the validation of the CA decides
+			err = certLib.CheckHttp01(domain, csrList.Domains[i].Token, keyAuth)
+			if err != nil {
+				log.Printf("domain: %s: could not fetch http-01 key authorization: %v -- accepting the challenge anyway", domain, err)
 			} else {
-				// need to parse err for 127.0.0.53:53
-				errStr := err.Error()
-				log.Printf("*** errStr: %s\n", errStr)
-				idx := strings.Index(errStr, "127.0.0.53:53")
-				if idx>-1 {
-					log.Printf("domain: %s -- attempt{%d]: no acme challenge record!", i+1, acmeDomain)
-				} else {
-					log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)
-				}
-//				log.Printf("Lookup no acme chal rec found! -- sleeping %d\n", i+1)
-				time.Sleep(10 * time.Second)
+				log.Printf("domain: %s: http-01 key authorization reachable!\n", domain)
 			}
+			continue
 		}
 
-		if rdAttempt < 0 {
-			log.Printf("domain: %s: could not look-up acme record!", domain)
+		// the tls-alpn-01 listener is only checked by the CA
+		if certLib.GetChalType(csrList.Domains[i]) == certLib.ChalTlsAlpn01 {continue}
+
+		// check DNS Record via LookUp
+		acmeDomain := run.chalRecs[i].Name
+
+		// a wildcard and its apex need both tokens at the same record name
+		log.Printf("checking %s on the name servers of zone %s\n", acmeDomain, run.chalRecs[i].Zone.Name)
+		err := run.propChk.WaitTxt(run.chalRecs[i].Zone.Name, acmeDomain, []string{csrList.Domains[i].TokVal})
+		if err != nil {
+			log.Printf("domain: %s: could not look-up acme record: %v", domain, err)
 			lookup = false
 		} else {
-			log.Printf("domain: %s: Lookup successful in %d attempts!\n", domain, rdAttempt +1)
+			log.Printf("domain: %s: Lookup successful!\n", domain)
 		}
 	}
 
 	if !lookup {
-		log.Printf("Could not lookup Acme Chal records for all domains!\n")
+		log.Printf("Could not lookup Acme Chal records for all domains! -- run again to resume\n")
 		os.Exit(1)
 	}
+	run.setState(certLib.IssuePropagated)
+}
 
-	//  at this point the dns chal records are all propaged. So we can process the challenge
-ProcOrder:
-	log.Printf("arrived at ProcOrd\n")
+// function that publishes the http-01 and tls-alpn-01 challenges of the order again
+func (run *issueRun) republish() {
+
+	for _, dom := range run.csrList.Domains {
+		if len(dom.ChalRecId) == 0 {continue}
+		switch certLib.GetChalType(dom) {
+		case certLib.ChalHttp01:
+			keyAuth, err := run.client.HTTP01ChallengeResponse(dom.Token)
+			if err != nil {log.Fatalf("http-01 key authorization for %s: %v", dom.Domain, err)}
+			err = run.httpSolver.Present(dom.Token, keyAuth)
+			if err != nil {log.Fatalf("httpSolver.Present: %v", err)}
+		case certLib.ChalTlsAlpn01:
+			chalCert, err := run.client.TLSALPN01ChallengeCert(dom.Token, dom.Domain)
+			if err != nil {log.Fatalf("tls-alpn-01 challenge cert for %s: %v", dom.Domain, err)}
+			err = run.tlsSolver.Present(dom.Domain, chalCert)
+			if err != nil {log.Fatalf("tlsSolver.Present: %v", err)}
+		}
+	}
+}
 
-//	orderUrl := order.URI
-	ordUrl := csrList.OrderUrl
-	if len(ordUrl) == 0 {log.Fatalf("no order Url in CsrListFile!\n")}
+// state propagated: asks the CA to validate the challenges
+func (run *issueRun) accept() (err error) {
 
 	// ready for sending an accept; checked dns propogation with lookup
-	for i:=0; i< numAcmeDom; i++ {
-		dom := csrList.Domains[i]
+	for _, dom := range run.csrList.Domains {
+		chalType := certLib.GetChalType(dom)
+
 		chalVal := acme.Challenge{
-			Type: "dns-01",
+			Type: chalType,
 			URI: dom.TokUrl,
 			Token: dom.Token,
 			Status: "pending",
 		}
-		if dbg {certLib.PrintChallenge(&chalVal, dom.Domain)}
+		if run.dbg {certLib.PrintChallenge(&chalVal, dom.Domain)}
 
 		domain := dom.Domain
 		log.Printf("sending Accept for domain %s\n", domain)
 
-		chal, err := client.Accept(ctx, &chalVal)
-		if err != nil {log.Fatalf("dns-01 chal not accepted for %s: %v", domain, err)}
-		if dbg {certLib.PrintChallenge(chal, domain)}
+		chal, err := run.client.Accept(run.ctx, &chalVal)
+		if err != nil {
+			log.Printf("%s chal not accepted for %s: %v", chalType, domain, err)
+			return err
+		}
+		if run.dbg {certLib.PrintChallenge(chal, domain)}
  		log.Printf("chal accepted for domain %s\n", domain)
-
 	}
 
-	tmpord, err := client.GetOrder(ctx, ordUrl)
-	if err !=nil {log.Fatalf("order error: %v\n", err)}
-	if dbg {certLib.PrintOrder(*tmpord)}
+	run.setState(certLib.IssueChallengesAccepted)
+	return nil
+}
+
+// state challenges-accepted: waits until the CA has validated the order
+func (run *issueRun) waitOrder() (err error) {
+
+	ordUrl := run.csrList.OrderUrl
+	tmpord, err := run.client.GetOrder(run.ctx, ordUrl)
+	if err !=nil {
+		log.Printf("order error: %v\n", err)
+		return err
+	}
+	if run.dbg {certLib.PrintOrder(*tmpord)}
 
     log.Printf("waiting for order\n")
-	if dbg {log.Printf("order url: %s\n", ordUrl)}
+	if run.dbg {log.Printf("order url: %s\n", ordUrl)}
 
-    ordUrl2, err := client.WaitOrder(ctx, ordUrl)
+    ordUrl2, err := run.client.WaitOrder(run.ctx, ordUrl)
     if err != nil {
 		if ordUrl2 != nil {certLib.PrintOrder(*ordUrl2)}
-		log.Fatalf("client.WaitOrder: %v\n",err)
+		log.Printf("client.WaitOrder: %v\n",err)
+		return err
 	}
 	log.Printf("received order!\n")
-	if dbg {certLib.PrintOrder(*ordUrl2)}
+	if run.dbg {certLib.PrintOrder(*ordUrl2)}
 
-	csrData := csrList.Domains[0]
+	run.setState(certLib.IssueOrderReady)
+	return nil
+}
 
-		//certLib.PrintCsr(csrData)
-	domain := csrData.Domain
-	log.Printf("generating certificate for domain: %s\n", domain)
-		// get certificates
-	certNam, err :=certLib.GenerateCertName(domain)
-	if err != nil {log.Fatalf("GenerateCertName: %v", err)}
-	if dbg {log.Printf("certNam: %s\n", certNam)}
+// state order-ready: sends the csr of each certificate
+// the certificate url is saved after each certificate
+func (run *issueRun) finalize() (err error) {
+
+	csrList := run.csrList
+	ctx := run.ctx
+	client := run.client
+	issue := &csrList.Issue
+
+	for k:=0; k< len(issue.Certs); k++ {
+		cert := &issue.Certs[k]
+		if len(cert.CertUrl) > 0 {continue}
+
+		// the authorizations of the first order are valid; the CA reuses them for the second order of a dual csr list
+		orderUrl := csrList.OrderUrl
+		if k > 0 {
+			if len(cert.OrderUrl) == 0 {
+				dualReplacesId := ""
+				// after a failover the current certificate was not necessarily issued by this CA
+				if issue.AcntIdx == 0 {dualReplacesId = replacesCertId(run.certDir, cert.CertNam, run.dbg)}
+				dualOrder, err := certLib.AuthorizeOrderAri(ctx, client, run.authIdList, dualReplacesId)
+				if err != nil && len(dualReplacesId) > 0 {
+					log.Printf("AuthorizeOrderAri: %v -- sending order without replaces\n", err)
+					dualOrder, err = client.AuthorizeOrder(ctx, run.authIdList)
+				}
+				if err != nil {
+					log.Printf("client.AuthorizeOrder %s: %v\n", cert.CertNam, err)
+					return err
+				}
+				cert.OrderUrl = dualOrder.URI
+				run.saveState()
+			}
+			orderUrl = cert.OrderUrl
+		}
 
-	keyFilnam := certObj.CertDir + "/" + certNam + ".key"
-	certFilnam := certObj.CertDir + "/" + certNam + ".crt"
-	log.Printf("key file: %s cert file: %s\n", keyFilnam, certFilnam)
+		order, err := client.WaitOrder(ctx, orderUrl)
+		if err != nil {
+			log.Printf("client.WaitOrder %s: %v\n", cert.CertNam, err)
+			return err
+		}
+		if run.dbg {certLib.PrintOrder(*order)}
+
+		// a crashed run may have finalized the order; the key of the csr is already saved
+		if order.Status == acme.StatusValid {
+			log.Printf("order of %s is already finalized\n", cert.CertNam)
+			cert.CertUrl = order.CertURL
+			run.saveState()
+			continue
+		}
 
-//	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	certKey, err := certLib.GenCertKey()
-	if err != nil {
-		log.Fatalf("GenCertKey: %v\n",err)
+		// a new key is saved under a new key id (file key store: <certNam>.key.new)
+		// the current certificate keeps its key until the new certificate is saved
+		keyId := certLib.CertKeyId(csrList.KeyStore, run.certDir, cert.CertNam)
+		newKeyId := certLib.NewCertKeyId(csrList.KeyStore, run.certDir, cert.CertNam)
+		certKey, reused, err := certLib.LoadCertKey(run.keyStore, keyId, newKeyId, cert.KeyType, run.keyPolicy, run.keyMaxAge)
+		if err != nil {
+			log.Fatalf("LoadCertKey: %v\n",err)
+		}
+		if reused {
+			log.Printf("Cert Request: reusing %s key %s!\n", cert.KeyType, keyId)
+			cert.NewKeyId = ""
+		} else {
+			log.Printf("Cert Request: %s key %s generated!\n", cert.KeyType, newKeyId)
+			cert.NewKeyId = newKeyId
+		}
+		run.saveState()
+
+		csrTpl, err := certLib.CreateCsrTplNew(csrList, -1)
+		if err != nil {	log.Fatalf("CreateCsrTpl: %v",err)}
+		csrTpl.SignatureAlgorithm, err = certLib.SigAlgo(cert.KeyType)
+		if err != nil {	log.Fatalf("SigAlgo: %v",err)}
+
+		csr, err := certLib.CreateCsr(csrTpl, certKey)
+		if err != nil {	log.Fatalf("CreateCertReq: %v",err)}
+
+		csrParseReq, err := certLib.ParseCsr(csr)
+		if err != nil {log.Fatalf("Error parsing certificate request: %v", err)}
+
+		// need to compare csrParse and template
+		certLib.PrintCsrReq(csrParseReq)
+
+		log.Printf("FinalUrl: %s\n", order.FinalizeURL)
+
+		_, certUrl, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+		if err != nil {
+			log.Printf("CreateOrderCert: %v\n",err)
+			return err
+		}
+		if run.dbg {log.Printf("certUrl: %s\n", certUrl)}
+
+		cert.CertUrl = certUrl
+		run.saveState()
 	}
-	log.Printf("Cert Request: key generated!\n")
 
-	err = certLib.SaveKeyPem(certKey, keyFilnam)
-	if err != nil {log.Fatalf("certLib.SaveKeypem: %v",err)}
-	log.Printf("Save: key saved as PEM!\n")
+	run.setState(certLib.IssueFinalized)
+	return nil
+}
 
-	csrTpl, err := certLib.CreateCsrTplNew(csrList, -1)
-	if err != nil {	log.Fatalf("CreateCsrTpl: %v",err)}
+// state finalized: downloads and saves each certificate with its meta file
+func (run *issueRun) download() (err error) {
 
-//	csr, err := x509.CreateCertificateRequest(rand.Reader, &csrTpl, certKey)
-//	if err != nil {	log.Fatalf("CreateCertReq: %v",err)}
+	csrList := run.csrList
+	issue := &csrList.Issue
 
-	csr, err := certLib.CreateCsr(csrTpl, certKey)
-	if err != nil {	log.Fatalf("CreateCertReq: %v",err)}
+	for k:=0; k< len(issue.Certs); k++ {
+		cert := &issue.Certs[k]
+		if cert.Saved {continue}
 
-	csrParseReq, err := certLib.ParseCsr(csr)
-	if err != nil {log.Fatalf("Error parsing certificate request: %v", err)}
+		derCerts, err := run.client.FetchCert(run.ctx, cert.CertUrl, true)
+		if err != nil {
+			log.Printf("client.FetchCert %s: %v\n", cert.CertNam, err)
+			return err
+		}
 
-	// need to compare csrParse and template
-	certLib.PrintCsrReq(csrParseReq)
+		derCerts, err = certLib.FetchPreferredChain(run.ctx, run.client, cert.CertUrl, run.caProf.PreferredChain, derCerts)
+		if err != nil {log.Fatalf("FetchPreferredChain: %v\n", err)}
 
-	FinalUrl := ordUrl2.FinalizeURL
-	log.Printf("FinalUrl: %s\n", FinalUrl)
+		// write the pem encoded certificate chain to file
+		certFilnam := run.certDir + "/" + cert.CertNam + ".crt"
+		log.Printf("Saving certificate to: %s", certFilnam)
 
-	derCerts, certUrl, err := client.CreateOrderCert(ctx, FinalUrl, csr, true)
-	if err != nil {log.Fatalf("CreateOrderCert: %v\n",err)}
+		err = certLib.SaveCertsPem(derCerts, certFilnam)
+		if err != nil {log.Fatalf("SaveCerts: %v\n",err)}
 
-	if dbg {log.Printf("derCerts: %d certUrl: %s\n", len(derCerts), certUrl)}
+		// the new key replaces the key of the previous certificate only now
+		keyId := certLib.CertKeyId(csrList.KeyStore, run.certDir, cert.CertNam)
+		if len(cert.NewKeyId) > 0 {
+			keyId, err = run.keyStore.CommitKey(cert.NewKeyId, keyId)
+			if err != nil {log.Fatalf("CommitKey %s: %v\n", cert.NewKeyId, err)}
+			log.Printf("new key %s is the key of %s\n", cert.NewKeyId, cert.CertNam)
+		}
 
-	csrList.CertUrl = certUrl
-	// write the pem encoded certificate chain to file
-	log.Printf("Saving certificate to: %s", certFilnam)
+		orderUrl := csrList.OrderUrl
+		if len(cert.OrderUrl) > 0 {orderUrl = cert.OrderUrl}
+
+		// the meta file records the csr file, so that the renewal daemon can renew the certificate
+		certMeta, err := certLib.NewCertMeta(cert.CertNam, run.csrFil, csrList, derCerts)
+		if err != nil {log.Fatalf("NewCertMeta: %v\n", err)}
+		certMeta.OrderUrl = orderUrl
+		certMeta.CertUrl = cert.CertUrl
+		certMeta.Account = issue.Account
+		certMeta.CAProfile = run.caProf.Name
+		certMeta.CAUrl = run.client.DirectoryURL
+		certMeta.KeyId = keyId
+		leafCert, err := x509.ParseCertificate(derCerts[0])
+		if err != nil {log.Fatalf("x509.ParseCertificate: %v\n", err)}
+		err = certLib.UpdateCertAri(certMeta, run.client.HTTPClient, run.client.DirectoryURL, leafCert)
+		if err != nil {log.Printf("no ari renewal window: %v\n", err)}
+		err = certLib.WriteCertMeta(certLib.CertMetaFilnam(run.certDir, cert.CertNam), certMeta)
+		if err != nil {log.Fatalf("WriteCertMeta: %v\n", err)}
+		if run.dbg {certLib.PrintCertMeta(certMeta)}
+
+		// the certificate is saved even if it cannot be recorded in the inventory
+		certRec, err := certLib.NewCertRec(cert.CertNam, derCerts)
+		if err == nil {
+			certRec.AddMeta(certMeta)
+			certRec.CertFil = certFilnam
+			certRec.KeyFil = keyId
+			err = certLib.RecordCert(run.invFilnam, certRec)
+		}
+		if err != nil {log.Printf("cert %s is not recorded in the inventory: %v\n", cert.CertNam, err)}
 
-	err = certLib.SaveCertsPem(derCerts, certFilnam)
-	if err != nil {log.Fatalf("SaveCerts: %v\n",err)}
+		cert.Saved = true
+		cert.NewKeyId = ""
+		run.saveState()
+	}
+
+	csrList.CertUrl = issue.Certs[0].CertUrl
+	run.setState(certLib.IssueDownloaded)
+	return nil
+}
+
+// state downloaded: removes the challenges and the order data of the csr list
+func (run *issueRun) cleanup() {
+
+	csrList := run.csrList
 
 	// cleanup
-	for i:=0; i< numAcmeDom; i++ {
-		acmeZone := acmeDomList[i]
-		acmeZone.AcmeId = csrList.Domains[i].ChalRecId
+	err := cleanupChals(csrList, run.chalRecs, run.dnsProv, run.httpSolver, run.tlsSolver)
+	if err != nil {log.Fatalf("cleanupChals: %v\n",err)}
+
+	if run.dbg {certLib.PrintCsrList(csrList) }
+	certLib.ResetCsrOrder(csrList)
+	csrList.LastLU = time.Now()
+	run.setState(certLib.IssueCleanedUp)
+	log.Printf("success writing Csr File\n")
+}
+
+// function that converts a csr file of a version without issue state
+// challenges in all domains correspond to the former resume point: the records were published
+func legacyIssueState(csrList *certLib.CsrList, acntNam string) {
 
-		err = cfApiObj.DelDnsChalRecord(acmeZone)
-    	if err != nil {log.Fatalf("DelDnsChalRecord: %v\n",err)}
-		log.Printf("deleted DNS Chal Record for zone: %s\n", acmeZone.Name)
+	chalRecs := 0
+	for _, dom := range csrList.Domains {
+		if len(dom.ChalRecId) > 0 {chalRecs++}
 	}
+	if chalRecs == 0 {return}
+	if chalRecs < len(csrList.Domains) {log.Fatalf("error mixed: some domains have acme chal recs, the csr list has no issue state")}
 
-	if dbg {certLib.PrintCsrList(csrList) }
-	err = certLib.CleanCsrFil(csrFilnam, csrList)
-	if err != nil {log.Fatalf("CleanCsrFil: %v\n",err)}
-	log.Printf("success writing Csr File\n")
+	log.Printf("found all domains contain acme chal recs; resuming in state %s!", certLib.IssueRecordsPresented)
+	csrList.Issue.State = certLib.IssueRecordsPresented
+	csrList.Issue.Account = acntNam
+	csrList.Issue.AcntIdx = 0
+}
 
-	log.Printf("success creating Certs\n")
+// function that removes the challenges of the order that have been published
+func cleanupChals(csrList *certLib.CsrList, chalRecs []certLib.ChalRec, dnsProv certLib.DNSProvider, httpSolver *certLib.Http01Solver, tlsSolver *certLib.TlsAlpn01Solver) (err error) {
+
+	for i:=0; i< len(csrList.Domains); i++ {
+		dom := csrList.Domains[i]
+		if len(dom.ChalRecId) == 0 {continue}
+
+		if certLib.GetChalType(dom) == certLib.ChalHttp01 {
+			err = httpSolver.CleanUp(dom.Token)
+			if err != nil {return fmt.Errorf("httpSolver.CleanUp: %v", err)}
+			log.Printf("deleted http-01 key authorization for domain: %s\n", dom.Domain)
+			continue
+		}
+		if certLib.GetChalType(dom) == certLib.ChalTlsAlpn01 {
+			err = tlsSolver.CleanUp(dom.Domain)
+			if err != nil {return fmt.Errorf("tlsSolver.CleanUp: %v", err)}
+			log.Printf("removed tls-alpn-01 challenge certificate for domain: %s\n", dom.Domain)
+			continue
+		}
+		chalRecs[i].RecId = dom.ChalRecId
+
+		err = dnsProv.CleanUp(&chalRecs[i])
+		if err != nil {return fmt.Errorf("dnsProv.CleanUp: %v", err)}
+		log.Printf("deleted DNS Chal Record for zone: %s\n", chalRecs[i].Zone.Name)
+	}
+	return nil
 }
 
+// function that returns the ARI id of the current certificate certNam, which the new order replaces
+// a missing certificate returns an empty id
+func replacesCertId(certDir string, certNam string, dbg bool) (replacesId string) {
+
+	oldCert, err := certLib.ReadLeafCert(certDir + "/" + certNam + ".crt")
+	if err != nil {return ""}
+	replacesId, err = certLib.AriCertId(oldCert)
+	if err != nil {
+		log.Printf("AriCertId: %v\n", err)
+		return ""
+	}
+	if dbg {log.Printf("order replaces cert %s: %s\n", certNam, replacesId)}
+	return replacesId
+}
@@ -15,11 +15,10 @@ import (
 	"fmt"
 	"os"
 	"time"
-	"net"
+//	"net"
 	"strings"
     "golang.org/x/crypto/acme"
 
-    cfLib "acme/acmeDns/cfLib"
 	certLib "acme/acmeDns/certLib"
     util "github.com/prr123/utility/utilLib"
 )
@@ -36,8 +35,9 @@ func main() {
 
 	useStr := "./createMultiCerts [/csr=csrfile][/dbg]"
     helpStr := "program that creates mutliple certificates, one for each of the domains listed in the file csrList.yaml\n"
-    helpStr += "requirements: - a file listing all cloudflare domains/zones controlled by this account\n"
-    helpStr += "              - a cloudflare authorisation file with a token that permits DNS record changes in the direcory cloudflare/token\n"
+    helpStr += "requirements: - a dns provider (default cloudflare) selected with dnsProvider in the csr file or the account file\n"
+    helpStr += "              - for cloudflare: a file listing all cloudflare domains/zones controlled by this account\n"
+    helpStr += "                and a cloudflare authorisation file with a token that permits DNS record changes in the direcory cloudflare/token\n"
 	helpStr += "              - a csr yaml file located in $LEAcnt/csrList\n"
 
 
@@ -80,27 +80,15 @@ func main() {
 	if dbg {certLib.PrintCertObj(certObj)}
 
 
-    zoneFilnam := certObj.ZoneFilnam
-    cfApiFilnam := certObj.CfApiFilnam
     csrFilnam = certObj.CsrDir + csrFilnam
 
     log.Printf("debug: %t\n", dbg)
-    log.Printf("Using zone file: %s\n", zoneFilnam)
     log.Printf("Using csr file: %s\n", csrFilnam)
 
-    cfApiObj, err := cfLib.InitCfApi(cfApiFilnam)
-    if err != nil {log.Fatalf("cfLib.InitCfApi: %v\n", err)}
-    log.Printf("success: init cf api\n")
-
-	// reading all domain names served by cloudflare
-    zoneList, err := cfLib.ReadZoneShortFile(zoneFilnam)
-    if err != nil {log.Fatalf("ReadZoneFileShort: %v\n", err)}
-	if dbg {log.Printf("success reading all cf zones!\n")}
-	if dbg {cfLib.PrintZoneList(zoneList)}
-
-	numZones := len(zoneList.Zones)
-    log.Printf("Acme Chal Domain Target: %d\n", numZones)
-	if numZones == 0 {log.Fatalf("no domains in file: %s\n", zoneFilnam)}
+	// a second run on the same csr file fails here
+	csrLock, err := certLib.LockCsrFil(csrFilnam)
+	if err != nil {log.Fatalf("LockCsrFil: %v", err)}
+	defer csrLock.Unlock()
 
 	// read list of all domains for Acme Challenge
     csrList, err := certLib.ReadCsrFil(csrFilnam)
@@ -114,30 +102,47 @@ func main() {
 	authIdList := make([]acme.AuthzID, 1)
 
 	if dbg {certLib.PrintCsrList(csrList)}
+
+	if certLib.UsesChalType(csrList, certLib.ChalHttp01) || certLib.UsesChalType(csrList, certLib.ChalTlsAlpn01) {
+		log.Fatalf("http-01 and tls-alpn-01 challenges are only supported by createCertsV3!\n")
+	}
+
+	// each certificate gets a key of the key type of its domain
+	err = certLib.CheckKeyTypes(csrList)
+	if err != nil {log.Fatalf("CheckKeyTypes: %v\n", err)}
+
+	keyStore, err := certLib.NewKeyStore(csrList.KeyStore)
+	if err != nil {log.Fatalf("NewKeyStore: %v\n", err)}
+	for i:=0; i< len(csrList.Domains); i++ {
+		err = certLib.CheckKeyPolicy(csrList.Domains[i])
+		if err != nil {log.Fatalf("domain %s: CheckKeyPolicy: %v\n", csrList.Domains[i].Domain, err)}
+	}
 //	log.Printf("certDir: %s\n", csrList.CertDir)
 
-	acmeDomList := make([]cfLib.ZoneAcme, numAcmeDom)
-	// see whether acme domains are in zoneList
+	leAcnt, err := certLib.ReadLEObj(csrList.AcntName)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
 
-    foundAllDom := true
-    for i:= 0; i< numAcmeDom; i++ {
-        acmeDomNam := csrList.Domains[i].Domain
-        foundDom := false
-        for j:=0; j< numZones; j++ {
-            if zoneList.Zones[j].Name == acmeDomNam {
-                acmeDomList[i].Name = acmeDomNam
-                acmeDomList[i].Id = zoneList.Zones[j].Id
-                foundDom = true
-                break
-            }
-        }
-        if !foundDom {
-            log.Printf("domain %s is not contained in the cf Zone List\n", acmeDomNam)
-            foundAllDom = false
-        }
-    }
+	// get the dns provider selected in the csr file or the account file
+	dnsProvNam := certLib.GetDnsProviderNam(csrList, leAcnt)
+	dnsProv, err := certLib.NewDnsProvider(dnsProvNam, certObj)
+	if err != nil {log.Fatalf("NewDnsProvider: %v\n", err)}
+	log.Printf("success: init dns provider %s\n", dnsProv.Name())
+
+	// reading all domain names served by the dns provider
+	zoneList, err := dnsProv.Zones()
+	if err != nil {log.Fatalf("dnsProv.Zones: %v\n", err)}
+
+	numZones := len(zoneList)
+    log.Printf("Acme Chal Domain Target: %d\n", numZones)
+	if numZones == 0 {log.Fatalf("no zones found for dns provider: %s\n", dnsProv.Name())}
+
+	// see whether acme domains are in zoneList
+	chalRecs, err := certLib.GetChalRecs(csrList, zoneList)
+	if err != nil {log.Fatalf("GetChalRecs: %v\n", err)}
 
-    if !foundAllDom {log.Fatalf("csr list file contains domains that are not in the cf account domain list!")}
+	// propagation checker that queries the authoritative name servers of the zones
+	propChk := certLib.NewPropChecker(csrList.Propagation)
+	propChk.Dbg = dbg
 
     allChalRec := true
     noChalRec := true
@@ -160,34 +165,25 @@ func main() {
     oldAcmeRec := false
     noAcmeRec := true
     for i:=0; i< numAcmeDom; i++ {
-        acmeDomList[i].AcmeRec = false
-        domain := acmeDomList[i].Name
-        acmeDomain := "_acme-challenge." + domain
+        acmeDomain := chalRecs[i].Name
 
         log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
 
-        txtrecs, err := net.LookupTXT(acmeDomain)
-        if err == nil {
-            log.Printf("received txtrec from Lookup\n")
-            if dbg {
-                fmt.Printf("txtrecs[%d]: %s\n", len(txtrecs), txtrecs[0])
-                fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
-            }
-            if len(txtrecs[0]) > 0 {noAcmeRec = false}
-            if txtrecs[0] != csrList.Domains[i].TokVal {
-                acmeDomList[i].AcmeRec = true
-                oldAcmeRec = true
-            }
-        } else {
-            errStr := err.Error()
-            log.Printf("*** errStr: %s\n", errStr)
-            idx := strings.Index(errStr, "127.0.0.53:53")
-            if idx>-1 {
-                log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
-            } else {
-                log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)
-            }
+        // query the authoritative name servers of the zone directly
+        txtrecs, err := propChk.LookupTxt(chalRecs[i].Zone.Name, acmeDomain)
+        if err != nil {log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)}
+        if len(txtrecs) == 0 {
+            log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
+            continue
         }
+        log.Printf("received txtrec from Lookup\n")
+        if dbg {
+            fmt.Printf("txtrecs[%d]: %v\n", len(txtrecs), txtrecs)
+            fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
+        }
+        noAcmeRec = false
+        // a wildcard and its apex share the record name
+        if certLib.HasOldTxt(txtrecs, certLib.ChalRecVals(chalRecs, acmeDomain)) {oldAcmeRec = true}
     }
 
     if oldAcmeRec {log.Fatalf("lookup found acme Dns chal records!")}
@@ -199,7 +195,7 @@ func main() {
     }
 
 	// retrieve acme client from LE keys
-    client, err := certLib.GetAcmeClient()
+    client, err := certLib.GetLEClient(csrList.AcntName, dbg)
     if err != nil {log.Fatalf("could not get Acme Client: certLib.GetLEAcnt: %v\n", err)}
     log.Printf("success obtaining Acme Client\n")
 
@@ -226,7 +222,7 @@ func main() {
 	log.Printf("**** Begin Loop ****\n")
 	for i:=0; i< numAcmeDom; i++ {
 		authIdList[0].Type = "dns"
-		authIdList[0].Value = acmeDomList[i].Name
+		authIdList[0].Value = chalRecs[i].Domain
 
 		// create order for CA
 		order, err := client.AuthorizeOrder(ctx, authIdList)
@@ -239,8 +235,6 @@ func main() {
 		domain := authIdList[0].Value
 		log.Printf("domain [%d]: %s\n", i+1, domain)
 
-		acmeZone := acmeDomList[i]
-
 		// get authorization from CA
 		auth, err := client.GetAuthorization(ctx, authUrl)
 		if err != nil {log.Fatalf("client.GetAuthorisation: %v\n",err)}
@@ -258,7 +252,7 @@ func main() {
 
 		if chal == nil {log.Fatalf("no dns-01 challenge avaliable for zone %s", domain)}
 		log.Printf("success obtaining challenge\n")
-		if dbg {certLib.PrintChallenge(chal, acmeZone.Name)}
+		if dbg {certLib.PrintChallenge(chal, domain)}
 
 		// get token for DNS challenge
 		tokVal, err := client.DNS01ChallengeRecord(chal.Token)
@@ -266,17 +260,17 @@ func main() {
 		log.Printf("success obtaining Dns token: %s\n", tokVal)
 
 		// create DNS challenge record
-		recId, err := cfApiObj.AddDnsChalRecord(acmeZone.Id, tokVal)
-		if err != nil {log.Fatalf("AddDnsChalRecord: %v", err)}
+		chalRecs[i].Value = tokVal
+		chalRecs[i].Exp = auth.Expires
+		err = dnsProv.Present(&chalRecs[i])
+		if err != nil {log.Fatalf("dnsProv.Present: %v", err)}
 
 		// save the id of the challenge record, so we can delete the record later
 
-		acmeDomList[i].AcmeId = recId
-
         csrList.Domains[i].TokVal = tokVal
         csrList.Domains[i].Token = chal.Token
         csrList.Domains[i].TokUrl = chal.URI
-        csrList.Domains[i].ChalRecId = recId
+        csrList.Domains[i].ChalRecId = chalRecs[i].RecId
         csrList.Domains[i].TokIssue = time.Now()
         csrList.Domains[i].TokExp = auth.Expires
         csrList.Domains[i].OrderUrl = order.URI
@@ -284,16 +278,15 @@ func main() {
 
 		// we can verify that the challenge record was set with the ns
         if dbg {
-            zoneId := acmeDomList[i].Id
-            dnsRecs, err := cfApiObj.ListDnsRecords(zoneId)
-            if err != nil {log.Fatalf("domain[%d]: %s apiObj.ListDnsRecords: %v\n", i+1, acmeDomList[i].Name, err)}
-            cfLib.PrintDnsRecs(dnsRecs)
+            dnsRecs, err := dnsProv.ListChalRecs(chalRecs[i].Zone)
+            if err != nil {log.Fatalf("domain[%d]: %s dnsProv.ListChalRecs: %v\n", i+1, domain, err)}
+            certLib.PrintChalRecs(dnsRecs)
         }
 
 
 //        domain := csrList.Domains[i].Domain
         // check DNS Record via LookUp
-        acmeDomain := "_acme-challenge." + domain
+        acmeDomain := chalRecs[i].Name
 		if dbg {log.Printf("challenge domain: %s\n", acmeDomain)}
 
 	// end of the loop
@@ -302,9 +295,9 @@ func main() {
 	// at this point we should have an order for each domain
 	// the challenge records for each domain should have been set
 	csrList.LastLU = time.Now()
-	err = certLib.WriteCsrFil(csrFilnam, csrList)
-	if err != nil {log.Fatal("certLib.WriteCsrFil: %v\n", err)}
-	log.Printf("csrList written")
+	err = certLib.SaveCsrState(csrFilnam, csrList)
+	if err != nil {log.Fatalf("certLib.SaveCsrState: %v\n", err)}
+	log.Printf("csr state saved")
 
 
 	// check whether lookup can retrieve the record for each domain
@@ -312,28 +305,17 @@ func main() {
 		domain := csrList.Domains[i].Domain
 
        // check DNS Record via LookUp
-        acmeDomain := "_acme-challenge." + domain
-
-		rdAttempt := -1
-		for i:= 0; i< 5; i++ {
-			txtrecs, err := net.LookupTXT(acmeDomain)
-			if err == nil {
-				log.Printf("received txtrec from Lookup\n")
-				if dbg {fmt.Printf("txtrecs [%d]: %s\n", len(txtrecs), txtrecs[0])}
-				rdAttempt = i;
-				break
-			} else {
-				log.Printf("Lookup err: %v - sleeping %d\n", err, i+1)
-				time.Sleep(10 * time.Second)
-			}
-		}
+        acmeDomain := chalRecs[i].Name
 
-        if rdAttempt < 0 {
-            log.Printf("domain: %s: could not look-up acme record!", domain)
-            lookup = false
-        } else {
-            log.Printf("domain: %s: Lookup successful in %d attempts!\n", domain, rdAttempt +1)
-        }
+		// a wildcard and its apex need both tokens at the same record name
+		log.Printf("checking %s on the name servers of zone %s\n", acmeDomain, chalRecs[i].Zone.Name)
+		err := propChk.WaitTxt(chalRecs[i].Zone.Name, acmeDomain, []string{csrList.Domains[i].TokVal})
+		if err != nil {
+			log.Printf("domain: %s: could not look-up acme record: %v", domain, err)
+			lookup = false
+		} else {
+			log.Printf("domain: %s: Lookup successful!\n", domain)
+		}
     }
 
     if !lookup {
@@ -398,14 +380,19 @@ ProcOrder:
 		certFilNam := certObj.CertDir + "/" + certNam + ".crt"
 		log.Printf("key file: %s cert file: %s\n", keyFilNam, certFilNam)
 
-		// generate keys for Certificate
-		certKey, err := certLib.GenCertKey()
-	    if err != nil {log.Fatalf("GenCertKey: %v\n",err)}
-    	log.Printf("Cert Request: key generated!\n")
-
-		// save private key for Certificate
-		err = certLib.SaveKeyPem(certKey, keyFilNam)
-		if err != nil {log.Fatalf("certLib.SaveKeypem: %v",err)}
+		// generate keys for Certificate or reuse the key of the current certificate
+		keyPolicy, keyMaxAge := certLib.GetKeyPolicy(csrData)
+		// a new key is saved as keyFilNam.new; it replaces keyFilNam once the certificate is saved
+		keyId := certLib.CertKeyId(csrList.KeyStore, certObj.CertDir, certNam)
+		newKeyId := certLib.NewCertKeyId(csrList.KeyStore, certObj.CertDir, certNam)
+		certKey, reused, err := certLib.LoadCertKey(keyStore, keyId, newKeyId, certLib.GetKeyType(csrData), keyPolicy, keyMaxAge)
+	    if err != nil {log.Fatalf("LoadCertKey: %v\n",err)}
+
+		if reused {
+			log.Printf("Cert Request: reusing key!\n")
+		} else {
+	    	log.Printf("Cert Request: key generated!\n")
+		}
 
 		// create csr template
 		csrTemplate := certLib.CreateCsrTpl(csrData)
@@ -434,6 +421,34 @@ ProcOrder:
 		err = certLib.SaveCertsPem(derCerts, certFilNam)
         if err != nil {log.Fatalf("SaveCerts: %v\n",err)}
 
+		if !reused {
+			keyId, err = keyStore.CommitKey(newKeyId, keyId)
+			if err != nil {log.Fatalf("CommitKey %s: %v\n", newKeyId, err)}
+		}
+
+		// the meta file records the csr file, so that the renewal daemon can renew the certificate
+		// each domain has its own certificate; the renewal has to run createMultiCerts again
+		certMeta, err := certLib.NewCertMeta(certNam, strings.TrimPrefix(csrFilnam, certObj.CsrDir), csrList, derCerts)
+		if err != nil {log.Fatalf("NewCertMeta: %v\n", err)}
+		certMeta.Cmd = "createMultiCerts"
+		certMeta.Domains = []string{domain}
+		certMeta.OrderUrl = orderUrl
+		certMeta.CertUrl = certUrl
+		certMeta.CAUrl = client.DirectoryURL
+		certMeta.KeyId = keyId
+		err = certLib.WriteCertMeta(certLib.CertMetaFilnam(certObj.CertDir, certNam), certMeta)
+		if err != nil {log.Fatalf("WriteCertMeta: %v\n", err)}
+
+		// the certificate is saved even if it cannot be recorded in the inventory
+		certRec, err := certLib.NewCertRec(certNam, derCerts)
+		if err == nil {
+			certRec.AddMeta(certMeta)
+			certRec.CertFil = certFilNam
+			certRec.KeyFil = keyId
+			err = certLib.RecordCert(certObj.InventoryFilnam, certRec)
+		}
+		if err != nil {log.Printf("cert %s is not recorded in the inventory: %v\n", certNam, err)}
+
 	}
 
 	log.Printf("success creating Certs\n")
@@ -441,12 +456,11 @@ ProcOrder:
 	// cleanup
 	log.Printf("Start cleanup\n")
 	for i:=0; i< numAcmeDom; i++ {
-		acmeZone := acmeDomList[i]
-		acmeZone.AcmeId = csrList.Domains[i].ChalRecId
+		chalRecs[i].RecId = csrList.Domains[i].ChalRecId
 
-		err = cfApiObj.DelDnsChalRecord(acmeZone)
-    	if err != nil {log.Fatalf("DelDnsChalRecord: %v\n",err)}
-		log.Printf("deleted DNS Chal Record for zone: %s\n", acmeZone.Name)
+		err = dnsProv.CleanUp(&chalRecs[i])
+    	if err != nil {log.Fatalf("dnsProv.CleanUp: %v\n",err)}
+		log.Printf("deleted DNS Chal Record for zone: %s\n", chalRecs[i].Zone.Name)
 	}
 
     err = certLib.CleanCsrFil(csrFilnam, csrList)
@@ -12,8 +12,8 @@ package main
 
 import (
 	"context"
-    "crypto/ecdsa"
-    "crypto/elliptic"
+//    "crypto/ecdsa"
+//    "crypto/elliptic"
     "crypto/rand"
     "crypto/x509"
 
@@ -21,14 +21,13 @@ import (
 	"fmt"
 	"os"
 	"time"
-	"net"
+//	"net"
 	"strings"
 
 //    yaml "github.com/goccy/go-yaml"
 	"golang.org/x/crypto/acme"
 //	"github.com/cloudflare/cloudflare-go"
 
-    cfLib "acme/acmeDns/cfLib"
 	certLib "acme/acmeDns/certLib"
 )
 
@@ -46,26 +45,17 @@ func main() {
 
 	useStr := "./createSingleCert [domain] [/csr=file] [/dbg]"
 	helpStr := "program that creates a cert for the domain specified in the CLI\n"
-	helpStr += "requirements: - a file listing all cloudflare domains/zones controlled by this account\n"
-	helpStr += "              - a cloudflare authorisation file with a token that permits DNS record changes in the direcory cloudflare/token\n"
+	helpStr += "requirements: - a dns provider (default cloudflare) selected with dnsProvider in the csr file or the account file\n"
+	helpStr += "              - for cloudflare: a file listing all cloudflare domains/zones controlled by this account\n"
+	helpStr += "                and a cloudflare authorisation file with a token that permits DNS record changes in the direcory cloudflare/token\n"
 	helpStr += "              - the specified domain must be listed in the file csrList.yaml\n"
 
 
-	zoneDir := os.Getenv("zoneDir")
-	if len(zoneDir) == 0 {log.Fatalf("could not resolve env var zoneDir!")}
-
 	certDir := os.Getenv("certDir")
 	if len(certDir) == 0 {log.Fatalf("could not resolve env var certDir!")}
 
-    zoneFilnam := zoneDir + "/cfDomainsShort.yaml"
-
 	csrFilnam := "csrList.yaml"
 
-    cfDir := os.Getenv("Cloudflare")
-	if len(cfDir) == 0 {log.Fatalf("could not resolve env var cfDir!")}
-
-    cfApiFilnam := cfDir + "/token/cfDns.yaml"
-
 	if numarg > 4 {
 		fmt.Println("too many arguments in cl!")
 		fmt.Println("usage: %s\n", useStr)
@@ -89,34 +79,42 @@ func main() {
 	}
 
 	log.Printf("Target Domain:   %s\n", tgtDomain)
-	log.Printf("Using zone file: %s\n", zoneFilnam)
 	log.Printf("Using csr file:  %s\n", csrFilnam)
 
-	cfApiObj, err := cfLib.InitCfApi(cfApiFilnam)
-	if err != nil {log.Fatalf("cfLib.InitCfApi: %v\n", err)}
-	log.Printf("success: init cf api\n")
+	certObj, err := certLib.InitCertLib()
+	if err != nil {log.Fatalf("InitCertLib: %v\n", err)}
 
     // creating context
     ctx := context.Background()
 
-	// reading all domain names served by cloudflare
-    zoneList, err := cfLib.ReadZoneShortFile(zoneFilnam)
-    if err != nil {log.Fatalf("ReadZoneFileShort: %v\n", err)}
-
-	log.Printf("success reading all cf zones!\n")
-	if dbg {cfLib.PrintZoneList(zoneList)}
-
-	numZones := len(zoneList.Zones)
-
-	log.Printf("Acme Chal Domain Target: %d\n", numZones)
-	if numZones == 0 {log.Fatalf("no domains in file: %s\n", zoneFilnam)}
-
+	// a second run on the same csr file fails here
+	csrLock, err := certLib.LockCsrFil(csrFilnam)
+	if err != nil {log.Fatalf("LockCsrFil: %v", err)}
+	defer csrLock.Unlock()
 
 	// read list of all domains for Acme Challenge
     csrList, err := certLib.ReadCsrFil(csrFilnam)
     if err != nil {log.Fatalf("ReadCsrFil: %v", err)}
 	log.Printf("success reading CsrFile!\n")
 
+	leAcnt, err := certLib.ReadLEObj(csrList.AcntName)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
+
+	// get the dns provider selected in the csr file or the account file
+	dnsProvNam := certLib.GetDnsProviderNam(csrList, leAcnt)
+	dnsProv, err := certLib.NewDnsProvider(dnsProvNam, certObj)
+	if err != nil {log.Fatalf("NewDnsProvider: %v\n", err)}
+	log.Printf("success: init dns provider %s\n", dnsProv.Name())
+
+	// reading all domain names served by the dns provider
+	zoneList, err := dnsProv.Zones()
+	if err != nil {log.Fatalf("dnsProv.Zones: %v\n", err)}
+
+	numZones := len(zoneList)
+
+	log.Printf("Acme Chal Domain Target: %d\n", numZones)
+	if numZones == 0 {log.Fatalf("no zones found for dns provider: %s\n", dnsProv.Name())}
+
 	numAcmeDom := len(csrList.Domains)
 	tgtDomId :=-1
 	for i:=0; i<numAcmeDom; i++ {
@@ -134,19 +132,31 @@ func main() {
 
 	if dbg {certLib.PrintCsrList(csrList)}
 
-	acmeDomList := make([]cfLib.ZoneAcme, numAcmeDom)
+	if certLib.UsesChalType(csrList, certLib.ChalHttp01) || certLib.UsesChalType(csrList, certLib.ChalTlsAlpn01) {
+		log.Fatalf("http-01 and tls-alpn-01 challenges are only supported by createCertsV3!\n")
+	}
+
+	keyType, err := certLib.CsrKeyType(csrList)
+	if err != nil {log.Fatalf("CsrKeyType: %v\n", err)}
+	keyPolicy, keyMaxAge, err := certLib.CsrKeyPolicy(csrList)
+	if err != nil {log.Fatalf("CsrKeyPolicy: %v\n", err)}
+
+	keyStore, err := certLib.NewKeyStore(csrList.KeyStore)
+	if err != nil {log.Fatalf("NewKeyStore: %v\n", err)}
+
+	chalRecs := make([]certLib.ChalRec, numAcmeDom)
 
 	// see whether acme domains are in zoneList
-	found := false
-	for i:=0; i<len(zoneList.Zones); i++ {
-		if zoneList.Zones[i].Name == tgtDomain {
-			acmeDomList[0].Name = tgtDomain
-			acmeDomList[0].Id = zoneList.Zones[i].Id
-			found = true
-			break
-		}
-	}
-	if !found {log.Fatalf("target domain is not listed in the cf domain list!\n")}
+	zone, err := certLib.FindZone(tgtDomain, zoneList)
+	if err != nil {log.Fatalf("FindZone: %v\n", err)}
+	chalRecs[0].Domain = tgtDomain
+	chalRecs[0].Zone = zone
+	chalRecs[0].Name = certLib.ChalRecName(tgtDomain)
+	chalRecs[0].RelName = certLib.RelRecName(chalRecs[0].Name, zone.Name)
+
+	// propagation checker that queries the authoritative name servers of the zones
+	propChk := certLib.NewPropChecker(csrList.Propagation)
+	propChk.Dbg = dbg
 
 	// check whether acme domains have challenge records
 	// outcome is:
@@ -175,34 +185,25 @@ func main() {
 	oldAcmeRec := false
 	noAcmeRec := true
 	for i:=0; i< numAcmeDom; i++ {
-		acmeDomList[i].AcmeRec = false
-		domain := acmeDomList[i].Name
-		acmeDomain := "_acme-challenge." + domain
+		acmeDomain := chalRecs[i].Name
 
 		log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
 
-		txtrecs, err := net.LookupTXT(acmeDomain)
-		if err == nil {
-			log.Printf("received txtrec from Lookup\n")
-			if dbg {
-				fmt.Printf("txtrecs[%d]: %s\n", len(txtrecs), txtrecs[0])
-				fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
-			}
-			if len(txtrecs[0]) > 0 {noAcmeRec = false}
-			if txtrecs[0] != csrList.Domains[i].TokVal {
-				acmeDomList[i].AcmeRec = true
-				oldAcmeRec = true
-			}
-		} else {
-			errStr := err.Error()
-			log.Printf("*** errStr: %s\n", errStr)
-			idx := strings.Index(errStr, "127.0.0.53:53")
-			if idx>-1 {
-				log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
-			} else {
-				log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)
-			}
+		// query the authoritative name servers of the zone directly
+		txtrecs, err := propChk.LookupTxt(chalRecs[i].Zone.Name, acmeDomain)
+		if err != nil {log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)}
+		if len(txtrecs) == 0 {
+			log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
+			continue
 		}
+		log.Printf("received txtrec from Lookup\n")
+		if dbg {
+			fmt.Printf("txtrecs[%d]: %v\n", len(txtrecs), txtrecs)
+			fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
+		}
+		noAcmeRec = false
+		// a wildcard and its apex share the record name
+		if certLib.HasOldTxt(txtrecs, []string{csrList.Domains[i].TokVal}) {oldAcmeRec = true}
 	}
 
 	if oldAcmeRec {log.Fatalf("lookup found acme Dns chal records!")}
@@ -227,7 +228,7 @@ func main() {
 	// Authorize all domains provided in the cmd line args.
 	for i:=0; i< numAcmeDom; i++ {
 		authIdList[i].Type = "dns"
-		authIdList[i].Value = acmeDomList[i].Name
+		authIdList[i].Value = chalRecs[i].Domain
 	}
 
 	// lets encrypt does not accept preauthorisation
@@ -248,7 +249,6 @@ func main() {
 		log.Printf("domain [%d]: %s\n", i+1, domain)
 
 		url := order.AuthzURLs[i]
-		acmeZone := acmeDomList[i]
 
 		auth, err := client.GetAuthorization(ctx, url)
 		if err != nil {log.Fatalf("client.GetAuthorisation: %v\n",err)}
@@ -268,40 +268,40 @@ func main() {
 		if chal == nil {log.Fatalf("dns-01 challenge is not available for zone %s", domain)}
 
 		log.Printf("success obtaining challenge\n")
-		if dbg {certLib.PrintChallenge(chal, acmeZone.Name)}
+		if dbg {certLib.PrintChallenge(chal, domain)}
 
 		// Fulfill the challenge.
 		tokVal, err := client.DNS01ChallengeRecord(chal.Token)
 		if err != nil {log.Fatalf("dns-01 token for %s: %v", domain, err)}
 		log.Printf("success obtaining Dns token: %s\n", tokVal)
 
-		recId, err := cfApiObj.AddDnsChalRecord(acmeZone.Id, tokVal)
-		if err != nil {log.Fatalf("AddDnsChalRecord: %v", err)}
-		acmeDomList[i].AcmeId = recId
+		chalRecs[i].Value = tokVal
+		chalRecs[i].Exp = auth.Expires
+		err = dnsProv.Present(&chalRecs[i])
+		if err != nil {log.Fatalf("dnsProv.Present: %v", err)}
 
 		csrList.Domains[i].TokVal = tokVal
 		csrList.Domains[i].Token = chal.Token
 		csrList.Domains[i].TokUrl = chal.URI
-		csrList.Domains[i].ChalRecId = recId
+		csrList.Domains[i].ChalRecId = chalRecs[i].RecId
 		csrList.Domains[i].TokIssue = time.Now()
 		csrList.Domains[i].TokExp = auth.Expires
 
 		if dbg {
-			zoneId := acmeDomList[i].Id
-	        dnsRecs, err := cfApiObj.ListDnsRecords(zoneId)
-    	    if err != nil {log.Fatalf("domain[%d]: %s apiObj.ListDnsRecords: %v\n", i+1, acmeDomList[i].Name, err)}
-			cfLib.PrintDnsRecs(dnsRecs)
+	        dnsRecs, err := dnsProv.ListChalRecs(chalRecs[i].Zone)
+    	    if err != nil {log.Fatalf("domain[%d]: %s dnsProv.ListChalRecs: %v\n", i+1, domain, err)}
+			certLib.PrintChalRecs(dnsRecs)
 		}
 
-		log.Printf("%s: success creating dns record!\n", acmeDomList[i].Name)
+		log.Printf("%s: success creating dns record!\n", domain)
 
 	}
 	log.Printf("success creating all dns challenge records!")
 
 	csrList.LastLU = time.Now()
 	csrList.OrderUrl = order.URI
-	err = certLib.WriteCsrFil(csrFilnam, csrList)
-	if err != nil {log.Fatal("certLib.WriteCsrFil: %v\n", err)}
+	err = certLib.SaveCsrState(csrFilnam, csrList)
+	if err != nil {log.Fatalf("certLib.SaveCsrState: %v\n", err)}
 
 //	os.Exit(1)
 
@@ -312,40 +312,16 @@ func main() {
 		domain := authIdList[i].Value
 
 		// check DNS Record via LookUp
-		acmeDomain := "_acme-challenge." + domain
-
-		log.Printf("waiting 2 sec before performing ns.Lookup\n")
-		time.Sleep(2 * time.Second)
-		log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
+		acmeDomain := chalRecs[i].Name
 
-		rdAttempt := -1
-		for i:= 0; i< 5; i++ {
-			txtrecs, err := net.LookupTXT(acmeDomain)
-			if err == nil {
-				log.Printf("received txtrec from Lookup\n")
-				if dbg {fmt.Printf("txtrecs [%d]: %s\n", len(txtrecs), txtrecs[0])}
-				rdAttempt = i;
-				break
-			} else {
-				// need to parse err for 127.0.0.53:53
-				errStr := err.Error()
-				log.Printf("*** errStr: %s\n", errStr)
-				idx := strings.Index(errStr, "127.0.0.53:53")
-				if idx>-1 {
-					log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
-				} else {
-					log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)
-				}
-//				log.Printf("Lookup no acme chal rec found! -- sleeping %d\n", i+1)
-				time.Sleep(10 * time.Second)
-			}
-		}
-
-		if rdAttempt < 0 {
-			log.Printf("domain: %s: could not look-up acme record!", domain)
+		// a wildcard and its apex need both tokens at the same record name
+		log.Printf("checking %s on the name servers of zone %s\n", acmeDomain, chalRecs[i].Zone.Name)
+		err := propChk.WaitTxt(chalRecs[i].Zone.Name, acmeDomain, []string{csrList.Domains[i].TokVal})
+		if err != nil {
+			log.Printf("domain: %s: could not look-up acme record: %v", domain, err)
 			lookup = false
 		} else {
-			log.Printf("domain: %s: Lookup successful in %d attempts!\n", domain, rdAttempt +1)
+			log.Printf("domain: %s: Lookup successful!\n", domain)
 		}
 	}
 
@@ -410,18 +386,19 @@ ProcOrder:
 	if err != nil {log.Fatalf("GenerateCertName: %v", err)}
 	if dbg {log.Printf("certNam: %s\n", certNam)}
 
-	keyFilnam := certDir + "/" + certNam + ".key"
+	// a new key is generated under a new key id; it replaces the key of the certificate once the certificate is saved
+	keyId := certLib.CertKeyId(csrList.KeyStore, certDir, certNam)
+	newKeyId := certLib.NewCertKeyId(csrList.KeyStore, certDir, certNam)
 	certFilnam := certDir + "/" + certNam + ".crt"
-	log.Printf("key file: %s cert file: %s\n", keyFilnam, certFilnam)
+	log.Printf("key: %s cert file: %s\n", keyId, certFilnam)
 
-	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		log.Fatalf("GenerateKey: %v\n",err)
+	certKey, reused, err := certLib.LoadCertKey(keyStore, keyId, newKeyId, keyType, keyPolicy, keyMaxAge)
+	if err != nil {log.Fatalf("LoadCertKey: %v\n",err)}
+	if reused {
+		log.Printf("Cert Request: reusing key!\n")
+	} else {
+		log.Printf("Cert Request: key generated!\n")
 	}
-	log.Printf("Cert Request: key generated!\n")
-
-	err = certLib.SaveKeyPem(certKey, keyFilnam)
-	if err != nil {log.Fatalf("certLib.SaveKeypem: %v",err)}
 
 	csrTpl, err := certLib.CreateCsrTplNew(csrList, -1)
 	if err != nil {	log.Fatalf("CreateCsrTpl: %v",err)}
@@ -449,13 +426,38 @@ ProcOrder:
 	err = certLib.SaveCertsPem(derCerts, certFilnam)
 	if err != nil {log.Fatalf("SaveCerts: %v\n",err)}
 
+	if !reused {
+		keyId, err = keyStore.CommitKey(newKeyId, keyId)
+		if err != nil {log.Fatalf("CommitKey %s: %v\n", newKeyId, err)}
+	}
+
+	// the meta file records the csr file, so that the renewal daemon can renew the certificate
+	// the certificate has the name and the domains of a createCertsV3 certificate of the csr file, so createCertsV3 renews it
+	certMeta, err := certLib.NewCertMeta(certNam, strings.TrimPrefix(csrFilnam, certObj.CsrDir), csrList, derCerts)
+	if err != nil {log.Fatalf("NewCertMeta: %v\n", err)}
+	certMeta.CertUrl = certUrl
+	certMeta.CAUrl = client.DirectoryURL
+	certMeta.KeyId = keyId
+	err = certLib.WriteCertMeta(certLib.CertMetaFilnam(certDir, certNam), certMeta)
+	if err != nil {log.Fatalf("WriteCertMeta: %v\n", err)}
+
+	// the certificate is saved even if it cannot be recorded in the inventory
+	certRec, err := certLib.NewCertRec(certNam, derCerts)
+	if err == nil {
+		certRec.AddMeta(certMeta)
+		certRec.CertFil = certFilnam
+		certRec.KeyFil = keyId
+		err = certLib.RecordCert(certObj.InventoryFilnam, certRec)
+	}
+	if err != nil {log.Printf("cert %s is not recorded in the inventory: %v\n", certNam, err)}
+
 	// cleanup
 	for i:=0; i< numAcmeDom; i++ {
 
-		acmeZone := acmeDomList[i]
-		err = cfApiObj.DelDnsChalRecord(acmeZone)
-    	if err != nil {log.Fatalf("DelDnsChalRecord: %v\n",err)}
-		log.Printf("deleted DNS Chal Record for zone: %s\n", acmeZone.Name)
+		chalRecs[i].RecId = csrList.Domains[i].ChalRecId
+		err = dnsProv.CleanUp(&chalRecs[i])
+    	if err != nil {log.Fatalf("dnsProv.CleanUp: %v\n",err)}
+		log.Printf("deleted DNS Chal Record for zone: %s\n", chalRecs[i].Zone.Name)
 	}
 
     err = certLib.CleanCsrFil(csrFilnam, csrList)
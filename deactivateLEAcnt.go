@@ -0,0 +1,81 @@
+// deactivateLEAcnt.go
+// program that deactivates a CA account
+// a deactivated account cannot be reactivated; its certificates remain valid
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"log"
+	"fmt"
+	"os"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+	dbg := false
+	confirm := false
+	acntNam := ""
+
+    flags:=[]string{"dbg","acnt","confirm"}
+
+	useStr := "deactivateLEAcnt /acnt=name /confirm [/dbg]"
+	helpStr := "program that deactivates a CA account\n"
+	helpStr += "the deactivation cannot be undone; /confirm is required\n"
+
+	if numarg > 4 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+	if numarg > 1 && os.Args[1] == "help" {
+		fmt.Printf("help:\n%s\n", helpStr)
+		fmt.Printf("\nusage is: %s\n", useStr)
+		os.Exit(1)
+	}
+
+	flagMap, err := util.ParseFlags(os.Args, flags)
+	if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+	_, ok := flagMap["dbg"]
+	if ok {dbg = true}
+	if dbg {
+		for k, v :=range flagMap {
+			fmt.Printf("flag: /%s value: %s\n", k, v)
+		}
+	}
+
+	val, ok := flagMap["acnt"]
+	if !ok {log.Fatalf("no /acnt flag!\n")}
+	if val.(string) == "none" {log.Fatalf("no account name provided with /acnt flag!")}
+	acntNam = val.(string)
+
+	_, ok = flagMap["confirm"]
+	if ok {confirm = true}
+
+	log.Printf("debug: %t\n", dbg)
+	log.Printf("account name: %s\n", acntNam)
+
+	leAcnt, err := certLib.ReadLEObj(acntNam)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
+	certLib.PrintLEAcnt(leAcnt)
+
+	if !confirm {
+		fmt.Printf("usage is: %s\n", useStr)
+		log.Fatalf("the deactivation of account %s cannot be undone -- repeat with /confirm!\n", acntNam)
+	}
+
+	leAcnt, err = certLib.DeactivateLEAcnt(acntNam, dbg)
+	if err != nil {log.Fatalf("DeactivateLEAcnt: %v\n", err)}
+
+	log.Printf("success: deactivated account %s on %s\n", leAcnt.AcntNam, leAcnt.Deactivated.Format("2006-01-02"))
+}
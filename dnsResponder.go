@@ -0,0 +1,110 @@
+// dnsResponder.go
+// program that runs an authoritative dns server for the acme dns-01 challenge records
+// the challenge tokens are written by the responder dns provider into the token file
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"log"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+    dbg := false
+    flags:=[]string{"dbg","addr"}
+
+	useStr := "dnsResponder [/addr=host:port] [/dbg]"
+	helpStr := "program that answers the dns queries for the acme challenge records delegated to this host\n"
+	helpStr += "the configuration is read from $LEAcnt/responder/responder.yaml\n"
+	helpStr += "the tokens are read from $LEAcnt/responder/chalToks.yaml\n"
+
+	addr := ""
+	if numarg > 3 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+    if numarg > 1 {
+        if os.Args[1] == "help" {
+            fmt.Printf("help:\n%s\n", helpStr)
+            fmt.Printf("\nusage is: %s\n", useStr)
+            os.Exit(1)
+        }
+        flagMap, err := util.ParseFlags(os.Args, flags)
+        if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+        _, ok := flagMap["dbg"]
+        if ok {dbg = true}
+        if dbg {
+            for k, v :=range flagMap {
+                fmt.Printf("k: %s v: %s\n", k, v)
+            }
+        }
+        val, ok := flagMap["addr"]
+        if ok {
+            if val.(string) == "none" {log.Fatalf("no address provided with /addr flag!")}
+            addr = val.(string)
+        }
+    }
+
+	certObj, err := certLib.InitCertLib()
+    if err != nil {log.Fatalf("InitCertLib: %v\n", err)}
+    if dbg {certLib.PrintCertObj(certObj)}
+
+	cfg, err := certLib.ReadResponderFil(certObj.ResponderFilnam)
+	if err != nil {log.Fatalf("ReadResponderFil: %v\n", err)}
+	if len(addr) > 0 {cfg.Addr = addr}
+
+	log.Printf("debug: %t\n", dbg)
+	log.Printf("listen addr: %s\n", cfg.Addr)
+	log.Printf("token file:  %s\n", certObj.ChalTokFilnam)
+	if len(cfg.Zone) > 0 {log.Printf("cname zone:  %s\n", cfg.Zone)}
+
+	resp, err := certLib.NewChalResponder(cfg, certObj.ChalTokFilnam)
+	if err != nil {log.Fatalf("NewChalResponder: %v\n", err)}
+	resp.Dbg = dbg
+
+	err = resp.Start()
+	if err != nil {log.Fatalf("responder Start: %v\n", err)}
+	log.Printf("success: responder started!\n")
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	// expired tokens are removed from the token file every minute
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			num, err := resp.PurgeToks()
+			if err != nil {
+				log.Printf("PurgeToks: %v\n", err)
+				continue
+			}
+			if num > 0 {log.Printf("removed %d expired tokens\n", num)}
+
+		case sig := <-sigChan:
+			log.Printf("received signal %v: stopping responder\n", sig)
+			resp.Stop()
+			log.Printf("success: responder stopped!\n")
+			return
+		}
+	}
+}
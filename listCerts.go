@@ -0,0 +1,136 @@
+// listCerts.go
+// program that lists the certificates of the certificate inventory
+// the inventory is filled by createCertsV3, createMultiCerts and createSingleCert; /scan adds the certificates of the cert directory
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"log"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+    dbg := false
+	long := false
+	scan := false
+    flags:=[]string{"dbg","exp","domain","acnt","cert","all","long","scan"}
+
+	useStr := "listCerts [/exp=days] [/domain=name] [/acnt=name] [/cert=certName] [/all] [/long] [/scan] [/dbg]"
+	helpStr := "program that lists the certificates of the inventory $LEAcnt/inventory/certs.db\n"
+	helpStr += "/exp: certificates that expire within days, including expired certificates\n"
+	helpStr += "/domain: certificates with the domain or a subdomain of the domain as SAN\n"
+	helpStr += "/acnt: certificates issued with the account\n"
+	helpStr += "/cert: certificates with the certificate name\n"
+	helpStr += "/all: all issued certificates instead of the current certificate of each name\n"
+	helpStr += "/long: prints all fields of each certificate\n"
+	helpStr += "/scan: adds the certificates of $LEAcnt/certs to the inventory before listing\n"
+
+	if numarg > 9 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+	if numarg > 1 && os.Args[1] == "help" {
+		fmt.Printf("help:\n%s\n", helpStr)
+		fmt.Printf("\nusage is: %s\n", useStr)
+		os.Exit(1)
+	}
+
+	filter := certLib.CertFilter{}
+	if numarg > 1 {
+		flagMap, err := util.ParseFlags(os.Args, flags)
+		if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+		_, ok := flagMap["dbg"]
+		if ok {dbg = true}
+		if dbg {
+			for k, v :=range flagMap {
+				fmt.Printf("k: %s v: %s\n", k, v)
+			}
+		}
+
+		val, ok := flagMap["exp"]
+		if ok {
+			if val.(string) == "none" {log.Fatalf("no number of days provided with /exp flag!")}
+			days, err := strconv.Atoi(val.(string))
+			if err != nil || days < 1 {log.Fatalf("invalid /exp flag: %s -- use a number of days!", val.(string))}
+			filter.ExpireDays = days
+		}
+
+		val, ok = flagMap["domain"]
+		if ok {
+			if val.(string) == "none" {log.Fatalf("no domain provided with /domain flag!")}
+			filter.Domain = strings.ToLower(val.(string))
+		}
+
+		val, ok = flagMap["acnt"]
+		if ok {
+			if val.(string) == "none" {log.Fatalf("no account name provided with /acnt flag!")}
+			filter.Account = val.(string)
+		}
+
+		val, ok = flagMap["cert"]
+		if ok {
+			if val.(string) == "none" {log.Fatalf("no certificate name provided with /cert flag!")}
+			filter.CertNam = val.(string)
+		}
+
+		_, ok = flagMap["all"]
+		if ok {filter.All = true}
+		_, ok = flagMap["long"]
+		if ok {long = true}
+		_, ok = flagMap["scan"]
+		if ok {scan = true}
+	}
+
+	certObj, err := certLib.InitCertLib()
+    if err != nil {log.Fatalf("InitCertLib: %v\n", err)}
+    if dbg {certLib.PrintCertObj(certObj)}
+
+	inv, err := certLib.OpenCertInventory(certObj.InventoryFilnam)
+	if err != nil {log.Fatalf("OpenCertInventory: %v\n", err)}
+	defer inv.Close()
+
+	if scan {
+		recs, err := certLib.ScanCertDir(certObj.CertDir)
+		if err != nil {log.Fatalf("ScanCertDir: %v\n", err)}
+		for i:=0; i< len(recs); i++ {
+			err = inv.AddCert(&recs[i])
+			if err != nil {log.Fatalf("AddCert %s: %v\n", recs[i].CertNam, err)}
+		}
+		log.Printf("added %d certificates of %s\n", len(recs), certObj.CertDir)
+	}
+
+	recs, err := inv.ListCerts(&filter)
+	if err != nil {log.Fatalf("ListCerts: %v\n", err)}
+
+	if long {
+		for i:=0; i< len(recs); i++ {
+			certLib.PrintCertRec(&recs[i])
+		}
+		return
+	}
+
+	now := time.Now()
+	fmt.Printf("%-30s %-10s %5s %-8s %-16s %s\n", "cert", "expires", "days", "key", "account", "domains")
+	for _, rec := range recs {
+		days := int(rec.Expire.Sub(now).Hours() / 24)
+		fmt.Printf("%-30s %-10s %5d %-8s %-16s %s\n", rec.CertNam, rec.Expire.Format("2006-01-02"), days, rec.KeyType, rec.Account, strings.Join(rec.Domains, ","))
+	}
+	fmt.Printf("%d certificates\n", len(recs))
+}
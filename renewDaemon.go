@@ -0,0 +1,209 @@
+// renewDaemon.go
+// program that watches the expiry of the certificates in the cert directory and renews them
+// a certificate is renewed with the csr file recorded in its meta file
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"log"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+    dbg := false
+	once := false
+    flags:=[]string{"dbg","once","cmd"}
+
+	useStr := "renewDaemon [/cmd=renew program] [/once] [/dbg]"
+	helpStr := "program that renews the certificates in $LEAcnt/certs before they expire\n"
+	helpStr += "the renewal window is read from $LEAcnt/renew/renew.yaml\n"
+	helpStr += "the schedule is saved in $LEAcnt/renew/schedule.yaml\n"
+	helpStr += "/once: performs one scan and renews the certificates that are due\n"
+
+	cmdNam := ""
+	if numarg > 4 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+    if numarg > 1 {
+        if os.Args[1] == "help" {
+            fmt.Printf("help:\n%s\n", helpStr)
+            fmt.Printf("\nusage is: %s\n", useStr)
+            os.Exit(1)
+        }
+        flagMap, err := util.ParseFlags(os.Args, flags)
+        if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+        _, ok := flagMap["dbg"]
+        if ok {dbg = true}
+        if dbg {
+            for k, v :=range flagMap {
+                fmt.Printf("k: %s v: %s\n", k, v)
+            }
+        }
+        _, ok = flagMap["once"]
+        if ok {once = true}
+
+        val, ok := flagMap["cmd"]
+        if ok {
+            if val.(string) == "none" {log.Fatalf("no program provided with /cmd flag!")}
+            cmdNam = val.(string)
+        }
+    }
+
+	certObj, err := certLib.InitCertLib()
+    if err != nil {log.Fatalf("InitCertLib: %v\n", err)}
+    if dbg {certLib.PrintCertObj(certObj)}
+
+	renewCfg, err := certLib.ReadRenewCfg(certObj.RenewFilnam)
+	if err != nil {log.Fatalf("ReadRenewCfg: %v\n", err)}
+	if len(cmdNam) > 0 {renewCfg.Cmd = cmdNam}
+
+	log.Printf("debug: %t\n", dbg)
+	log.Printf("renew window: %d days fraction: %.3f jitter: %dh\n", renewCfg.Days, renewCfg.Fraction, renewCfg.Jitter)
+	log.Printf("renew program: %s\n", renewCfg.Cmd)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	for {
+		err = renewScan(certObj.CertDir, certObj.RenewSchedFilnam, renewCfg, dbg)
+		if err != nil {log.Printf("renewScan: %v\n", err)}
+		if once {break}
+
+		select {
+		case <-time.After(time.Duration(renewCfg.ScanInterval) * time.Minute):
+		case sig := <-sigChan:
+			log.Printf("received signal %v: stopping renewal daemon\n", sig)
+			return
+		}
+	}
+	log.Printf("success: renewal scan completed!\n")
+}
+
+// function that updates the schedule and renews the certificates that are due
+func renewScan(certDir string, schedFilnam string, renewCfg *certLib.RenewCfg, dbg bool) (err error) {
+
+	sched, err := certLib.ReadRenewSched(schedFilnam)
+	if err != nil {return fmt.Errorf("ReadRenewSched: %v", err)}
+
+	// refresh the renewal windows suggested by the CA (ARI)
+	certNames, err := certLib.ListCertNames(certDir)
+	if err != nil {return fmt.Errorf("ListCertNames: %v", err)}
+	for _, certNam := range certNames {
+		updated, err := certLib.RefreshCertAri(certDir, certNam, time.Now())
+		if err != nil {
+			if dbg {log.Printf("cert %s: ari: %v\n", certNam, err)}
+			continue
+		}
+		if updated {log.Printf("cert %s: updated ari renewal window\n", certNam)}
+	}
+
+	skipped, err := certLib.UpdateRenewSched(sched, certDir, renewCfg)
+	if err != nil {return fmt.Errorf("UpdateRenewSched: %v", err)}
+	for _, certNam := range skipped {
+		log.Printf("cert %s: no meta file -- cannot be renewed!\n", certNam)
+	}
+
+	err = certLib.WriteRenewSched(schedFilnam, sched)
+	if err != nil {return fmt.Errorf("WriteRenewSched: %v", err)}
+	if dbg {certLib.PrintRenewSched(sched)}
+
+	dueList := certLib.DueRenewals(sched, time.Now())
+	log.Printf("certs: %d due for renewal: %d\n", len(sched.Entries), len(dueList))
+
+	// the certificates of one csr file, e.g. the ecdsa and the rsa certificate of a dual csr list, are renewed by one run
+	type renewRun struct {
+		cmdNam string
+		csrFil string
+	}
+	var runs []renewRun
+	runEntries := map[renewRun][]int{}
+	for _, idx := range dueList {
+		run := renewRun{cmdNam: renewCmdNam(renewCfg.Cmd, sched.Entries[idx].Cmd), csrFil: sched.Entries[idx].CsrFil}
+		if _, ok := runEntries[run]; !ok {runs = append(runs, run)}
+		runEntries[run] = append(runEntries[run], idx)
+	}
+
+	for _, run := range runs {
+		var certNams []string
+		for _, idx := range runEntries[run] {
+			certNams = append(certNams, sched.Entries[idx].CertNam)
+		}
+		log.Printf("renewing certs %v with %s and csr file %s\n", certNams, run.cmdNam, run.csrFil)
+
+		runErr := runRenewCmd(run.cmdNam, run.csrFil)
+		for _, idx := range runEntries[run] {
+			entry := &sched.Entries[idx]
+			renewErr := runErr
+			if renewErr == nil {renewErr = checkRenewed(certDir, entry)}
+			if renewErr != nil {
+				log.Printf("cert %s: renewal failed: %v\n", entry.CertNam, renewErr)
+			} else {
+				log.Printf("cert %s: success renewing!\n", entry.CertNam)
+			}
+			certLib.SetRenewResult(entry, renewErr, renewCfg, time.Now())
+		}
+
+		// the schedule is saved after each renewal
+		err = certLib.WriteRenewSched(schedFilnam, sched)
+		if err != nil {return fmt.Errorf("WriteRenewSched: %v", err)}
+	}
+
+	// renewed certificates get a new renewal time
+	if len(dueList) > 0 {
+		_, err = certLib.UpdateRenewSched(sched, certDir, renewCfg)
+		if err != nil {return fmt.Errorf("UpdateRenewSched: %v", err)}
+		err = certLib.WriteRenewSched(schedFilnam, sched)
+		if err != nil {return fmt.Errorf("WriteRenewSched: %v", err)}
+	}
+	return nil
+}
+
+// function that returns the renewal program of a certificate
+// the program of the meta file is looked up in the directory of the configured program, or in PATH if it has no directory
+func renewCmdNam(cfgCmd string, metaCmd string) (cmdNam string) {
+
+	if len(metaCmd) == 0 {return cfgCmd}
+	if filepath.Base(cfgCmd) == cfgCmd {return metaCmd}
+	return filepath.Dir(cfgCmd) + "/" + metaCmd
+}
+
+// function that runs the renewal program for one csr file
+func runRenewCmd(cmdNam string, csrFil string) (err error) {
+
+	cmd := exec.Command(cmdNam, "/csr=" + csrFil)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("output of %s:\n%s\n", cmdNam, string(out))
+		return fmt.Errorf("%s: %v", cmdNam, err)
+	}
+	return nil
+}
+
+// function that checks that the renewal program replaced the certificate
+func checkRenewed(certDir string, entry *certLib.RenewEntry) (err error) {
+
+	leaf, err := certLib.ReadLeafCert(certDir + "/" + entry.CertNam + ".crt")
+	if err != nil {return fmt.Errorf("ReadLeafCert: %v", err)}
+	if !leaf.NotAfter.After(entry.NotAfter) {return fmt.Errorf("certificate was not replaced!")}
+	return nil
+}
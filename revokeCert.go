@@ -0,0 +1,125 @@
+// revokeCert.go
+// program that revokes a certificate of the cert directory
+// the revocation is recorded in the meta file of the certificate, so that it is not renewed
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"context"
+
+	"log"
+	"fmt"
+	"os"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+    dbg := false
+	useCertKey := false
+    flags:=[]string{"dbg","cert","acnt","reason","key"}
+
+	useStr := "revokeCert /cert=certName [/reason=reason] [/key=acnt|cert] [/acnt=name] [/dbg]"
+	helpStr := "program that revokes the certificate certName in $LEAcnt/certs\n"
+	helpStr += "/reason: unspecified (default), keyCompromise, affiliationChanged, superseded, cessationOfOperation or the reason code\n"
+	helpStr += "/key: the request is signed with the account key (acnt, default) or the private key of the certificate (cert)\n"
+	helpStr += "/acnt: account that signs the request; default is the account in the meta file of the certificate or LEAcnt\n"
+
+	if numarg > 6 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+	if numarg < 2 {
+		fmt.Printf("usage is: %s\n", useStr)
+		log.Fatalf("no certificate name provided!\n")
+	}
+
+	if os.Args[1] == "help" {
+		fmt.Printf("help:\n%s\n", helpStr)
+		fmt.Printf("\nusage is: %s\n", useStr)
+		os.Exit(1)
+	}
+
+	flagMap, err := util.ParseFlags(os.Args, flags)
+	if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+	_, ok := flagMap["dbg"]
+	if ok {dbg = true}
+	if dbg {
+		for k, v :=range flagMap {
+			fmt.Printf("k: %s v: %s\n", k, v)
+		}
+	}
+
+	val, ok := flagMap["cert"]
+	if !ok {log.Fatalf("no /cert flag!\n")}
+	if val.(string) == "none" {log.Fatalf("no certificate name provided with /cert flag!")}
+	certNam := val.(string)
+
+	reasonStr := "unspecified"
+	val, ok = flagMap["reason"]
+	if ok {
+		if val.(string) == "none" {log.Fatalf("no reason provided with /reason flag!")}
+		reasonStr = val.(string)
+	}
+	reason, err := certLib.ParseRevokeReason(reasonStr)
+	if err != nil {log.Fatalf("ParseRevokeReason: %v\n", err)}
+
+	val, ok = flagMap["key"]
+	if ok {
+		switch val.(string) {
+		case "acnt":
+		case "cert":
+			useCertKey = true
+		default:
+			log.Fatalf("invalid /key flag: %s -- use acnt or cert!", val.(string))
+		}
+	}
+
+	acntNam := ""
+	val, ok = flagMap["acnt"]
+	if ok {
+		if val.(string) == "none" {log.Fatalf("no account name provided with /acnt flag!")}
+		acntNam = val.(string)
+	}
+
+	certObj, err := certLib.InitCertLib()
+    if err != nil {log.Fatalf("InitCertLib: %v\n", err)}
+    if dbg {certLib.PrintCertObj(certObj)}
+
+	meta, err := certLib.ReadCertMeta(certLib.CertMetaFilnam(certObj.CertDir, certNam))
+	if err != nil {
+		log.Printf("cert %s has no meta file: %v\n", certNam, err)
+	} else {
+		if dbg {certLib.PrintCertMeta(meta)}
+		if !meta.Revoked.IsZero() {log.Printf("cert %s was already revoked on %s\n", certNam, meta.Revoked.Format("2006-01-02"))}
+		if len(acntNam) == 0 {acntNam = meta.Account}
+	}
+	if len(acntNam) == 0 {acntNam = "LEAcnt"}
+
+	log.Printf("debug: %t\n", dbg)
+	log.Printf("cert:    %s\n", certNam)
+	log.Printf("reason:  %s\n", certLib.RevokeReasonName(reason))
+	log.Printf("account: %s\n", acntNam)
+	log.Printf("cert key: %t\n", useCertKey)
+
+	// an account client is needed for the directory, even if the request is signed with the cert key
+	client, err := certLib.GetLEClient(acntNam, dbg)
+	if err != nil {log.Fatalf("GetLEClient: %v\n", err)}
+
+	ctx := context.Background()
+	err = certLib.RevokeCertFil(ctx, client, certObj.CertDir, certNam, useCertKey, reason)
+	if err != nil {log.Fatalf("RevokeCertFil: %v\n", err)}
+
+	log.Printf("success: revoked cert %s!\n", certNam)
+}
@@ -0,0 +1,112 @@
+// rolloverLEAcnt.go
+// program that replaces the key of a CA account
+// the previous key is archived in the folder LEAcnt/archive and removed after the keep period
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"context"
+
+	"log"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+	dbg := false
+	acntNam := ""
+	keepDays := certLib.DefaultKeyArchiveDays
+	maxAge := 0
+
+    flags:=[]string{"dbg","acnt","keep","maxage"}
+
+	useStr := "rolloverLEAcnt [/acnt=name] [/keep=days] [/maxage=days] [/dbg]"
+	helpStr := "program that replaces the key of a CA account with a new key\n"
+	helpStr += "/keep: days the previous key is kept in the archive; default 30\n"
+	helpStr += "/maxage: the key is only replaced if it is older than maxage days\n"
+
+	if numarg > 5 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+	if numarg > 1 && os.Args[1] == "help" {
+		fmt.Printf("help:\n%s\n", helpStr)
+		fmt.Printf("\nusage is: %s\n", useStr)
+		os.Exit(1)
+	}
+
+	flagMap, err := util.ParseFlags(os.Args, flags)
+	if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+	_, ok := flagMap["dbg"]
+	if ok {dbg = true}
+	if dbg {
+		for k, v :=range flagMap {
+			fmt.Printf("flag: /%s value: %s\n", k, v)
+		}
+	}
+
+	val, ok := flagMap["acnt"]
+	if ok {
+		if val.(string) == "none" {log.Fatalf("no account name provided with /acnt flag!")}
+		acntNam = val.(string)
+	}
+
+	val, ok = flagMap["keep"]
+	if ok {
+		keepDays, err = strconv.Atoi(val.(string))
+		if err != nil || keepDays < 0 {log.Fatalf("invalid /keep flag: %s!", val.(string))}
+	}
+
+	val, ok = flagMap["maxage"]
+	if ok {
+		maxAge, err = strconv.Atoi(val.(string))
+		if err != nil || maxAge < 0 {log.Fatalf("invalid /maxage flag: %s!", val.(string))}
+	}
+
+	log.Printf("debug: %t\n", dbg)
+	log.Printf("account name: %s\n", acntNam)
+	log.Printf("keep archived keys: %d days\n", keepDays)
+
+	leAcnt, err := certLib.ReadLEObj(acntNam)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
+	if dbg {certLib.PrintLEAcnt(leAcnt)}
+
+	keyAge := time.Since(leAcnt.Updated)
+	log.Printf("account key age: %d days\n", int(keyAge.Hours() / 24))
+
+	if maxAge > 0 && keyAge < time.Duration(maxAge) * 24 * time.Hour {
+		log.Printf("account key is younger than %d days -- no rollover\n", maxAge)
+	} else {
+		leAcnt, err = certLib.RolloverLEAcnt(acntNam, dbg)
+		if err != nil {log.Fatalf("RolloverLEAcnt: %v\n", err)}
+		log.Printf("success: replaced key of account %s\n", leAcnt.AcntNam)
+
+		// testing the new key
+		client, err := certLib.GetLEClient(acntNam, dbg)
+		if err != nil {log.Fatalf("GetLEClient: %v\n", err)}
+		_, err = client.GetReg(context.Background(), "")
+		if err != nil {log.Fatalf("GetReg with new key: %v\n", err)}
+		log.Printf("success retrieving account with new key\n")
+	}
+
+	removed, err := certLib.PruneKeyArchive(leAcnt, keepDays, time.Now())
+	if err != nil {log.Fatalf("PruneKeyArchive: %v\n", err)}
+	for _, filnam := range removed {
+		log.Printf("removed archived key: %s\n", filnam)
+	}
+}
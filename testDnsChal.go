@@ -17,11 +17,10 @@ import (
 	"fmt"
 	"os"
 //	"time"
-	"net"
-	"strings"
+//	"net"
+//	"strings"
 
     util "github.com/prr123/utility/utilLib"
-    cfLib "acme/acmeDns/cfLib"
 	certLib "acme/acmeDns/certLib"
 )
 
@@ -80,29 +79,15 @@ func main() {
     if dbg {certLib.PrintCertObj(certObj)}
 
 
-    zoneFilnam := certObj.ZoneFilnam
-//    cfApiFilnam := certObj.CfApiFilnam
     csrFilnam = certObj.CsrDir + csrFilnam
 
     log.Printf("debug: %t\n", dbg)
-    log.Printf("Using zone file: %s\n", zoneFilnam)
     log.Printf("Using csr file: %s\n", csrFilnam)
 
-//    cfApiObj, err := cfLib.InitCfApi(cfApiFilnam)
-//    if err != nil {log.Fatalf("cfLib.InitCfApi: %v\n", err)}
-//    log.Printf("success: init cf api\n")
-
-    // reading all domain names served by cloudflare
-    zoneList, err := cfLib.ReadZoneShortFile(zoneFilnam)
-    if err != nil {log.Fatalf("ReadZoneFileShort: %v\n", err)}
-    if dbg {log.Printf("success reading all cf zones!\n")}
-    if dbg {cfLib.PrintZoneList(zoneList)}
-
-	numZones := len(zoneList.Zones)
-
-	log.Printf("Acme Chal Domain Target: %d\n", numZones)
-	if numZones == 0 {log.Fatalf("no domains in file: %s\n", zoneFilnam)}
-
+	// a second run on the same csr file fails here
+	csrLock, err := certLib.LockCsrFil(csrFilnam)
+	if err != nil {log.Fatalf("LockCsrFil: %v", err)}
+	defer csrLock.Unlock()
 
 	// read list of all domains for Acme Challenge
     csrList, err := certLib.ReadCsrFil(csrFilnam)
@@ -116,29 +101,30 @@ func main() {
 
 	if dbg {certLib.PrintCsrList(csrList)}
 
-	acmeDomList := make([]cfLib.ZoneAcme, numAcmeDom)
-
-	// get api for DNS use default yaml file
-
-	foundAllDom := true
-	for i:= 0; i< numAcmeDom; i++ {
-		acmeDomNam := csrList.Domains[i].Domain
-		foundDom := false
-		for j:=0; j< numZones; j++ {
-			if zoneList.Zones[j].Name == acmeDomNam {
-				acmeDomList[i].Name = acmeDomNam
-				acmeDomList[i].Id = zoneList.Zones[j].Id
-				foundDom = true
-				break
-			}
-		}
-		if !foundDom {
-			log.Printf("domain %s is not contained in the cf Zone List\n", acmeDomNam)
-			foundAllDom = false
-		}
-	}
+	leAcnt, err := certLib.ReadLEObj(csrList.AcntName)
+	if err != nil {log.Fatalf("ReadLEObj: %v\n", err)}
 
-	if !foundAllDom {log.Fatalf("csr list file contains domains that are not in the cf account domain list!")}
+	// get the dns provider selected in the csr file or the account file
+	dnsProvNam := certLib.GetDnsProviderNam(csrList, leAcnt)
+	dnsProv, err := certLib.NewDnsProvider(dnsProvNam, certObj)
+	if err != nil {log.Fatalf("NewDnsProvider: %v\n", err)}
+	if dbg {log.Printf("success: init dns provider %s\n", dnsProv.Name())}
+
+	// reading all domain names served by the dns provider
+	zoneList, err := dnsProv.Zones()
+	if err != nil {log.Fatalf("dnsProv.Zones: %v\n", err)}
+
+	numZones := len(zoneList)
+
+	log.Printf("Acme Chal Domain Target: %d\n", numZones)
+	if numZones == 0 {log.Fatalf("no zones found for dns provider: %s\n", dnsProv.Name())}
+
+	chalRecs, err := certLib.GetChalRecs(csrList, zoneList)
+	if err != nil {log.Fatalf("GetChalRecs: %v\n", err)}
+
+	// propagation checker that queries the authoritative name servers of the zones
+	propChk := certLib.NewPropChecker(csrList.Propagation)
+	propChk.Dbg = dbg
 
 	// check whether acme domains have challenge records
 	// outcome is:
@@ -167,34 +153,26 @@ func main() {
 	oldAcmeRec := false
 	noAcmeRec := true
 	for i:=0; i< numAcmeDom; i++ {
-		acmeDomList[i].AcmeRec = false
-		domain := acmeDomList[i].Name
-		acmeDomain := "_acme-challenge." + domain
+		if certLib.GetChalType(csrList.Domains[i]) != certLib.ChalDns01 {continue}
+		acmeDomain := chalRecs[i].Name
 
 		log.Printf("performing ns.Lookup %s for DNS Challenge Record!\n", acmeDomain)
 
-		txtrecs, err := net.LookupTXT(acmeDomain)
-		if err == nil {
-			log.Printf("received txtrec from Lookup\n")
-			if dbg {
-				fmt.Printf("txtrecs[%d]: %s\n", len(txtrecs), txtrecs[0])
-				fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
-			}
-			if len(txtrecs[0]) > 0 {noAcmeRec = false}
-			if txtrecs[0] != csrList.Domains[i].TokVal {
-				acmeDomList[i].AcmeRec = true
-				oldAcmeRec = true
-			}
-		} else {
-			errStr := err.Error()
-//			log.Printf("*** errStr: %s\n", errStr)
-			idx := strings.Index(errStr, "127.0.0.53:53")
-			if idx>-1 {
-				log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
-			} else {
-				log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)
-			}
+		// query the authoritative name servers of the zone directly
+		txtrecs, err := propChk.LookupTxt(chalRecs[i].Zone.Name, acmeDomain)
+		if err != nil {log.Fatalf("domain: %s -- lookup: %v", acmeDomain, err)}
+		if len(txtrecs) == 0 {
+			log.Printf("domain: %s -- no acme challenge record!", acmeDomain)
+			continue
+		}
+		log.Printf("received txtrec from Lookup\n")
+		if dbg {
+			fmt.Printf("txtrecs[%d]: %v\n", len(txtrecs), txtrecs)
+			fmt.Printf("token:       %s\n", csrList.Domains[i].TokVal)
 		}
+		noAcmeRec = false
+		// a wildcard and its apex share the record name
+		if certLib.HasOldTxt(txtrecs, certLib.ChalRecVals(chalRecs, acmeDomain)) {oldAcmeRec = true}
 	}
 
 	if oldAcmeRec {log.Fatalf("lookup found acme Dns chal records!")}
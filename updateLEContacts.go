@@ -0,0 +1,79 @@
+// updateLEContacts.go
+// program that replaces the contacts of a CA account
+// author: prr azul software
+// date: 18 October 2026
+// copyright 2026 prr, azulsoftware
+//
+
+package main
+
+import (
+	"log"
+	"fmt"
+	"os"
+	"strings"
+
+	certLib "acme/acmeDns/certLib"
+    util "github.com/prr123/utility/utilLib"
+)
+
+
+func main() {
+
+	numarg := len(os.Args)
+	dbg := false
+	acntNam := ""
+	// nil: the contacts of the account file are sent
+	var contacts []string
+
+    flags:=[]string{"dbg","acnt","contacts"}
+
+	useStr := "updateLEContacts [/acnt=name] [/contacts=email,email] [/dbg]"
+	helpStr := "program that replaces the contacts of a CA account\n"
+	helpStr += "/contacts: comma separated list of email addresses\n"
+	helpStr += "without /contacts the contacts of the account file are sent to the CA\n"
+
+	if numarg > 4 {
+		fmt.Println(useStr)
+		fmt.Println("too many arguments in cl!")
+		os.Exit(-1)
+	}
+
+	if numarg > 1 && os.Args[1] == "help" {
+		fmt.Printf("help:\n%s\n", helpStr)
+		fmt.Printf("\nusage is: %s\n", useStr)
+		os.Exit(1)
+	}
+
+	flagMap, err := util.ParseFlags(os.Args, flags)
+	if err != nil {log.Fatalf("util.ParseFlags: %v\n", err)}
+
+	_, ok := flagMap["dbg"]
+	if ok {dbg = true}
+	if dbg {
+		for k, v :=range flagMap {
+			fmt.Printf("flag: /%s value: %s\n", k, v)
+		}
+	}
+
+	val, ok := flagMap["acnt"]
+	if ok {
+		if val.(string) == "none" {log.Fatalf("no account name provided with /acnt flag!")}
+		acntNam = val.(string)
+	}
+
+	val, ok = flagMap["contacts"]
+	if ok {
+		if val.(string) == "none" {log.Fatalf("no email addresses provided with /contacts flag!")}
+		contacts = strings.Split(val.(string), ",")
+	}
+
+	log.Printf("debug: %t\n", dbg)
+	log.Printf("account name: %s\n", acntNam)
+
+	leAcnt, err := certLib.UpdateLEContacts(acntNam, contacts, dbg)
+	if err != nil {log.Fatalf("UpdateLEContacts: %v\n", err)}
+
+	certLib.PrintLEAcnt(leAcnt)
+	log.Printf("success: updated contacts of account %s\n", leAcnt.AcntNam)
+}